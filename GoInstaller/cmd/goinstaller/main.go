@@ -0,0 +1,398 @@
+/*
+    This file implements the build tool for GoInstaller payloads
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// go run ./cmd/goinstaller keygen private.key
+// go run ./cmd/goinstaller sign private.key
+// go run ./cmd/goinstaller compress
+// goinstaller pack stub /path/to/payload/directory installer
+
+package main
+
+import (
+    "encoding/binary"
+    "compress/flate"
+    "crypto/ed25519"
+    "crypto/sha256"
+    "encoding/hex"
+    "path/filepath"
+    "archive/zip"
+    "crypto/rand"
+    "strings"
+    "sort"
+    "fmt"
+    "io"
+    "os"
+)
+
+var categories = []string{"data", "program", "gui", "service", "config"}
+
+/*
+    The main function to starts the build tool.
+*/
+func main() {
+    if len(os.Args) < 2 {
+        usage()
+    }
+
+    switch os.Args[1] {
+    case "compress":
+        output := "payload.zip"
+        if len(os.Args) > 2 {
+            output = os.Args[2]
+        }
+        compress_payload(".", output)
+    case "payload":
+        if len(os.Args) < 4 {
+            usage()
+        }
+        write_payload_file(os.Args[2], os.Args[3])
+    case "pack":
+        if len(os.Args) < 5 {
+            usage()
+        }
+        pack(os.Args[2], os.Args[3], os.Args[4])
+    case "keygen":
+        if len(os.Args) < 3 {
+            usage()
+        }
+        generate_key(os.Args[2])
+    case "sign":
+        if len(os.Args) < 3 {
+            usage()
+        }
+        sign_payload(".", os.Args[2])
+    default:
+        usage()
+    }
+}
+
+/*
+    This function prints the usage and exit.
+*/
+func usage() {
+    fmt.Fprintf(os.Stderr, "USAGES: goinstaller compress [payload.zip]\n")
+    fmt.Fprintf(os.Stderr, "        goinstaller payload directory payload.bin\n")
+    fmt.Fprintf(os.Stderr, "        goinstaller pack stub directory installer\n")
+    fmt.Fprintf(os.Stderr, "        goinstaller keygen private.key\n")
+    fmt.Fprintf(os.Stderr, "        goinstaller sign private.key\n")
+    os.Exit(1)
+}
+
+/*
+    This function writes payload categories directories
+    in a compressed archive and reports the ratio.
+*/
+func compress_payload(source string, output string) {
+    file, err := os.Create(output)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", output, err)
+        os.Exit(2)
+    }
+    defer file.Close()
+
+    size := write_archive(file, source, category_names(source))
+
+    information, err := file.Stat()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading %s size: %v\n", output, err)
+        os.Exit(2)
+    }
+
+    report_ratio(size, information.Size(), output)
+}
+
+/*
+    This function prints the compression ratio.
+*/
+func report_ratio(size int64, compressed int64, output string) {
+    ratio := 100.0
+    if size > 0 {
+        ratio = float64(compressed) * 100 / float64(size)
+    }
+    fmt.Printf("Payload: %d bytes compressed to %d bytes (%.1f%%) in %s\n", size, compressed, ratio, output)
+}
+
+/*
+    This function returns payload files names from
+    categories directories.
+*/
+func category_names(source string) []string {
+    var names []string
+    for _, category := range categories {
+        entries, err := os.ReadDir(filepath.Join(source, category))
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error reading directory %s: %v\n", category, err)
+            os.Exit(2)
+        }
+
+        for _, entry := range entries {
+            if entry.Type().IsRegular() {
+                names = append(names, category + "/" + entry.Name())
+            }
+        }
+    }
+    return names
+}
+
+/*
+    This function writes files in a compressed archive
+    and returns the uncompressed size, categories
+    directories are always added to the archive.
+*/
+func write_archive(writer io.Writer, source string, names []string) int64 {
+    archive := zip.NewWriter(writer)
+    archive.RegisterCompressor(zip.Deflate, func(writer io.Writer) (io.WriteCloser, error) {
+        return flate.NewWriter(writer, flate.BestCompression)
+    })
+
+    for _, category := range categories {
+        _, err := archive.Create(category + "/")
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error adding %s: %v\n", category, err)
+            os.Exit(2)
+        }
+    }
+
+    var size int64
+    for _, name := range names {
+        size += add_file(archive, filepath.Join(source, filepath.FromSlash(name)), name)
+    }
+
+    err := archive.Close()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing archive: %v\n", err)
+        os.Exit(2)
+    }
+    return size
+}
+
+/*
+    This function adds a file to the archive and returns its size.
+*/
+func add_file(archive *zip.Writer, path string, name string) int64 {
+    source, err := os.Open(path)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+        os.Exit(2)
+    }
+    defer source.Close()
+
+    information, err := source.Stat()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+        os.Exit(2)
+    }
+
+    header, err := zip.FileInfoHeader(information)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+        os.Exit(2)
+    }
+    header.Name = name
+    header.Method = zip.Deflate
+
+    destination, err := archive.CreateHeader(header)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error adding %s: %v\n", name, err)
+        os.Exit(2)
+    }
+
+    size, err := io.Copy(destination, source)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error compressing %s: %v\n", path, err)
+        os.Exit(2)
+    }
+    return size
+}
+
+/*
+    This function generates an ed25519 key pair, the private
+    key is written to the file and the public key is printed
+    to pin it in the installer at build time.
+*/
+func generate_key(path string) {
+    public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error generating key: %v\n", err)
+        os.Exit(2)
+    }
+
+    err = os.WriteFile(path, []byte(hex.EncodeToString(private_key) + "\n"), 0600)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+        os.Exit(2)
+    }
+
+    fmt.Printf("Public key: %s\n", hex.EncodeToString(public_key))
+    fmt.Printf("Build flag: -ldflags \"-X main.payload_public_key=%s\"\n", hex.EncodeToString(public_key))
+}
+
+/*
+    This function writes the manifest of payload hashes
+    (signature/payload.sums) and its detached ed25519
+    signature (signature/payload.sig).
+*/
+func sign_payload(source string, key_path string) {
+    content, err := os.ReadFile(key_path)
+    if err == nil {
+        content, err = hex.DecodeString(strings.TrimSpace(string(content)))
+    }
+    if err != nil || len(content) != ed25519.PrivateKeySize {
+        fmt.Fprintf(os.Stderr, "Invalid private key: %s\n", key_path)
+        os.Exit(2)
+    }
+
+    names := append([]string{"manifest.json"}, category_names(source)...)
+    sort.Strings(names)
+
+    var sums strings.Builder
+    for _, name := range names {
+        sums.WriteString(hash_file(filepath.Join(source, filepath.FromSlash(name))) + "  " + name + "\n")
+    }
+
+    signature := ed25519.Sign(ed25519.PrivateKey(content), []byte(sums.String()))
+    write_signature_file(filepath.Join(source, "signature", "payload.sums"), sums.String())
+    write_signature_file(filepath.Join(source, "signature", "payload.sig"), hex.EncodeToString(signature) + "\n")
+    fmt.Printf("Payload signed: %d files\n", len(names))
+}
+
+/*
+    This function returns the SHA256 of a file or exit on error.
+*/
+func hash_file(path string) string {
+    file, err := os.Open(path)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", path, err)
+        os.Exit(2)
+    }
+    defer file.Close()
+
+    hasher := sha256.New()
+    _, err = io.Copy(hasher, file)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", path, err)
+        os.Exit(2)
+    }
+    return hex.EncodeToString(hasher.Sum(nil))
+}
+
+/*
+    This function writes a signature file or exit on error.
+*/
+func write_signature_file(path string, content string) {
+    err := os.WriteFile(path, []byte(content), 0644)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", path, err)
+        os.Exit(2)
+    }
+}
+
+const trailer_magic = "GOINSTPL"
+
+type counting_writer struct {
+    size int64
+}
+
+/*
+    This method counts written bytes.
+*/
+func (writer *counting_writer) Write(data []byte) (int, error) {
+    writer.size += int64(len(data))
+    return len(data), nil
+}
+
+/*
+    This function returns files of a payload directory
+    for the installer stub: the manifest, the signature
+    files when the payload is signed and categories files.
+*/
+func payload_names(source string) []string {
+    names := []string{"manifest.json"}
+    for _, name := range []string{"signature/payload.sums", "signature/payload.sig"} {
+        if _, err := os.Stat(filepath.Join(source, filepath.FromSlash(name))); err == nil {
+            names = append(names, name)
+        }
+    }
+    return append(names, category_names(source)...)
+}
+
+/*
+    This function appends the payload archive of a directory
+    and its trailer (archive size and SHA256) to a writer.
+*/
+func write_payload(writer io.Writer, source string, output string) {
+    hasher := sha256.New()
+    counter := &counting_writer{}
+    size := write_archive(io.MultiWriter(writer, hasher, counter), source, payload_names(source))
+
+    trailer := make([]byte, 16, 16 + sha256.Size)
+    copy(trailer, trailer_magic)
+    binary.LittleEndian.PutUint64(trailer[8:], uint64(counter.size))
+    trailer = hasher.Sum(trailer)
+
+    _, err := writer.Write(trailer)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing payload trailer: %v\n", err)
+        os.Exit(2)
+    }
+
+    report_ratio(size, counter.size, output)
+}
+
+/*
+    This function writes the payload of a directory in
+    a file to append it to the stub: cat stub payload.bin
+*/
+func write_payload_file(source string, output string) {
+    file, err := os.Create(output)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", output, err)
+        os.Exit(2)
+    }
+    defer file.Close()
+
+    write_payload(file, source, output)
+}
+
+/*
+    This function writes an installer from the prebuilt
+    stub and the payload of a directory.
+*/
+func pack(stub string, source string, output string) {
+    input, err := os.Open(stub)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error opening %s: %v\n", stub, err)
+        os.Exit(2)
+    }
+    defer input.Close()
+
+    file, err := os.OpenFile(output, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, 0755)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error creating %s: %v\n", output, err)
+        os.Exit(2)
+    }
+    defer file.Close()
+
+    _, err = io.Copy(file, input)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error copying %s: %v\n", stub, err)
+        os.Exit(2)
+    }
+
+    write_payload(file, source, output)
+}
@@ -0,0 +1,374 @@
+/*
+    This file implements answer files for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "encoding/json"
+    "path/filepath"
+    "strconv"
+    "strings"
+    "sort"
+    "fmt"
+    "os"
+)
+
+type Answers struct {
+    AcceptLicense bool `json:"accept_license"`
+    Components []string `json:"components"`
+    Location string `json:"location,omitempty"`
+    Variables map[string]string `json:"variables,omitempty"`
+    ServiceStart string `json:"service_start,omitempty"`
+}
+
+type Variable struct {
+    Name string `json:"name"`
+    Description string `json:"description"`
+    Default string `json:"default"`
+}
+
+var service_start_policies = []string{"start", "enable", "none"}
+
+/*
+    This method sets defaults of install choices: values
+    of the previous install or manifest defaults. Template
+    variables values replace "{{name}}" in commands and
+    variables are exported to commands environment.
+*/
+func (installer *Installer) default_choices() {
+    if installer.options.Location == "" {
+        installer.options.Location = installer.previous_receipt.Location
+    }
+    if installer.options.ServiceStart == "" {
+        installer.options.ServiceStart = installer.previous_receipt.ServiceStart
+    }
+
+    for _, variable := range installer.manifest.Variables {
+        installer.variables[variable.Name] = variable.Default
+        if value, ok := installer.previous_receipt.Variables[variable.Name]; ok {
+            installer.variables[variable.Name] = value
+        }
+    }
+}
+
+/*
+    This method loads an answer file (JSON, or YAML with
+    the ".yaml" and ".yml" extensions) with every
+    interactive choice, the wizard is not used.
+*/
+func (installer *Installer) load_answers(path string) error {
+    content, err := os.ReadFile(path)
+    if err != nil {
+        return failure(ExitUsage, "reading answers %s: %v", path, err)
+    }
+
+    var answers Answers
+    if yaml_file(path) {
+        answers, err = parse_yaml_answers(string(content))
+    } else {
+        err = json.Unmarshal(content, &answers)
+    }
+    if err != nil {
+        return failure(ExitUsage, "parsing answers %s: %v", path, err)
+    }
+
+    installer.options.Yes = true
+    installer.options.AcceptLicense = installer.options.AcceptLicense || answers.AcceptLicense
+    if answers.Location != "" {
+        installer.options.Location = answers.Location
+    }
+    if answers.ServiceStart != "" {
+        installer.options.ServiceStart = answers.ServiceStart
+    }
+
+    for name, value := range answers.Variables {
+        if _, ok := installer.variables[name]; !ok {
+            return failure(ExitUsage, "unknown variable: %s", name)
+        }
+        installer.variables[name] = value
+    }
+
+    if answers.Components != nil {
+        for name := range installer.selected_components {
+            installer.selected_components[name] = false
+        }
+        for _, name := range answers.Components {
+            if installer.find_component(name) == nil {
+                return failure(ExitUsage, "unknown component: %s", name)
+            }
+            installer.selected_components[name] = true
+        }
+
+        err = installer.resolve_dependencies(nil)
+        if err != nil {
+            return &Error{Code: ExitUsage, Err: err}
+        }
+    }
+    return nil
+}
+
+/*
+    This method writes install choices in an answer
+    file to replay the installation without prompt.
+*/
+func (installer *Installer) record_answers(path string) error {
+    answers := Answers{
+        AcceptLicense: installer.license_acceptance != nil,
+        Components: installer.installed_components(),
+        Location: installer.options.Location,
+        Variables: installer.variables,
+        ServiceStart: installer.options.ServiceStart,
+    }
+
+    var content []byte
+    var err error
+    if yaml_file(path) {
+        content = []byte(format_yaml_answers(answers))
+    } else {
+        content, err = json.MarshalIndent(answers, "", "    ")
+        content = append(content, '\n')
+    }
+    if err == nil {
+        err = os.WriteFile(path, content, 0600)
+    }
+    if err != nil {
+        return failure(ExitUsage, "writing answers %s: %v", path, err)
+    }
+    fmt.Printf("Answers recorded: %s\n", path)
+    return nil
+}
+
+/*
+    This method checks the service start policy.
+*/
+func (installer *Installer) check_service_start() error {
+    policy := installer.options.ServiceStart
+    if policy != "" && !contains(service_start_policies, policy) {
+        return failure(ExitUsage, "unknown service start policy: %s", policy)
+    }
+    return nil
+}
+
+/*
+    This method replaces "{{name}}" by variables values.
+*/
+func (installer *Installer) expand_variables(value string) string {
+    for name, variable := range installer.variables {
+        value = strings.ReplaceAll(value, "{{" + name + "}}", variable)
+    }
+    return value
+}
+
+/*
+    This method returns variables as environment variables.
+*/
+func (installer *Installer) variables_environment() []string {
+    var environment []string
+    for name, value := range installer.variables {
+        environment = append(environment, name + "=" + value)
+    }
+    return environment
+}
+
+/*
+    This function checks if an answer file is a YAML file.
+*/
+func yaml_file(path string) bool {
+    extension := strings.ToLower(filepath.Ext(path))
+    return extension == ".yaml" || extension == ".yml"
+}
+
+/*
+    This function parses a YAML answer file, only the subset
+    used by flat answer files is supported: "key: value"
+    scalars (plain or quoted), "true" and "false" booleans,
+    components as a block ("- name") or flow ("[a, b]") list,
+    variables as an indented "name: value" block and comments.
+*/
+func parse_yaml_answers(content string) (Answers, error) {
+    var answers Answers
+    section := ""
+    for number, line := range strings.Split(content, "\n") {
+        line = strings.TrimRight(strip_yaml_comment(line), " \t\r")
+        text := strings.TrimSpace(line)
+        if text == "" || text == "---" {
+            continue
+        }
+
+        indented := line[0] == ' ' || line[0] == '\t'
+        if section == "components" && (text == "-" || strings.HasPrefix(text, "- ")) {
+            answers.Components = append(answers.Components, yaml_scalar(text[1:]))
+            continue
+        }
+
+        key, value, ok := strings.Cut(text, ":")
+        if !ok {
+            return answers, fmt.Errorf("line %d: expected \"key: value\"", number + 1)
+        }
+        key = yaml_scalar(key)
+        value = strings.TrimSpace(value)
+
+        if indented && section == "variables" {
+            answers.Variables[key] = yaml_scalar(value)
+            continue
+        } else if indented {
+            return answers, fmt.Errorf("line %d: unexpected indentation", number + 1)
+        }
+
+        section = ""
+        var err error
+        switch key {
+        case "accept_license":
+            answers.AcceptLicense, err = strconv.ParseBool(yaml_scalar(value))
+        case "components":
+            answers.Components = []string{}
+            if value == "" {
+                section = key
+            } else {
+                answers.Components, err = yaml_flow_list(value)
+            }
+        case "variables":
+            answers.Variables = make(map[string]string)
+            if value == "" {
+                section = key
+            } else if value != "{}" {
+                err = fmt.Errorf("variables must be an indented block")
+            }
+        case "location":
+            answers.Location = yaml_scalar(value)
+        case "service_start":
+            answers.ServiceStart = yaml_scalar(value)
+        default:
+            err = fmt.Errorf("unknown key %q", key)
+        }
+
+        if err != nil {
+            return answers, fmt.Errorf("line %d: %v", number + 1, err)
+        }
+    }
+    return answers, nil
+}
+
+/*
+    This function removes a YAML comment: a "#" at the
+    start of the line or after a space, outside quotes.
+*/
+func strip_yaml_comment(line string) string {
+    var quote rune
+    for index, character := range line {
+        switch {
+        case quote != 0 && character == quote:
+            quote = 0
+        case quote != 0:
+        case character == '"' || character == '\'':
+            quote = character
+        case character == '#' && (index == 0 || line[index - 1] == ' ' || line[index - 1] == '\t'):
+            return line[:index]
+        }
+    }
+    return line
+}
+
+/*
+    This function returns the value of a YAML scalar,
+    double quoted strings use escapes and single quoted
+    strings use '' for a quote.
+*/
+func yaml_scalar(value string) string {
+    value = strings.TrimSpace(value)
+    if len(value) >= 2 && value[0] == '"' && value[len(value) - 1] == '"' {
+        unquoted, err := strconv.Unquote(value)
+        if err == nil {
+            return unquoted
+        }
+    }
+    if len(value) >= 2 && value[0] == '\'' && value[len(value) - 1] == '\'' {
+        return strings.ReplaceAll(value[1:len(value) - 1], "''", "'")
+    }
+    return value
+}
+
+/*
+    This function parses a YAML flow list: [a, "b", 'c'].
+*/
+func yaml_flow_list(value string) ([]string, error) {
+    if !strings.HasPrefix(value, "[") || !strings.HasSuffix(value, "]") {
+        return nil, fmt.Errorf("expected a list: %s", value)
+    }
+
+    values := []string{}
+    var quote rune
+    start := 1
+    for index, character := range value {
+        switch {
+        case index == 0:
+        case quote != 0 && character == quote:
+            quote = 0
+        case quote != 0:
+        case character == '"' || character == '\'':
+            quote = character
+        case character == ',' || index == len(value) - 1:
+            element := strings.TrimSpace(value[start:index])
+            if element != "" {
+                values = append(values, yaml_scalar(element))
+            }
+            start = index + 1
+        }
+    }
+    return values, nil
+}
+
+/*
+    This function writes answers as a YAML answer file,
+    strings are double quoted.
+*/
+func format_yaml_answers(answers Answers) string {
+    var content strings.Builder
+    fmt.Fprintf(&content, "accept_license: %t\n", answers.AcceptLicense)
+
+    if len(answers.Components) == 0 {
+        content.WriteString("components: []\n")
+    } else {
+        content.WriteString("components:\n")
+        for _, name := range answers.Components {
+            content.WriteString("  - " + strconv.Quote(name) + "\n")
+        }
+    }
+
+    if answers.Location != "" {
+        content.WriteString("location: " + strconv.Quote(answers.Location) + "\n")
+    }
+
+    if len(answers.Variables) > 0 {
+        var names []string
+        for name := range answers.Variables {
+            names = append(names, name)
+        }
+        sort.Strings(names)
+
+        content.WriteString("variables:\n")
+        for _, name := range names {
+            content.WriteString("  " + name + ": " + strconv.Quote(answers.Variables[name]) + "\n")
+        }
+    }
+
+    if answers.ServiceStart != "" {
+        content.WriteString("service_start: " + strconv.Quote(answers.ServiceStart) + "\n")
+    }
+    return content.String()
+}
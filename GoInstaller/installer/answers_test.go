@@ -0,0 +1,188 @@
+/*
+    This file tests answer files for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "path/filepath"
+    "reflect"
+    "testing"
+    "errors"
+    "os"
+)
+
+/*
+    This function tests the YAML answer files parser:
+    scalars, booleans, block and flow lists, variables,
+    comments and invalid files.
+*/
+func TestParseYamlAnswers(t *testing.T) {
+    tests := []struct {
+        name string
+        content string
+        expected Answers
+        fails bool
+    }{
+        {
+            name: "all keys",
+            content: "---\naccept_license: true\ncomponents:\n  - core\n  - \"docs\"\nlocation: /opt/app\nvariables:\n  PORT: \"8080\"\n  HOST: 'local''host'\nservice_start: enable\n",
+            expected: Answers{
+                AcceptLicense: true,
+                Components: []string{"core", "docs"},
+                Location: "/opt/app",
+                Variables: map[string]string{"PORT": "8080", "HOST": "local'host"},
+                ServiceStart: "enable",
+            },
+        },
+        {
+            name: "flow list",
+            content: "components: [core, \"a,b\", 'docs']\n",
+            expected: Answers{Components: []string{"core", "a,b", "docs"}},
+        },
+        {
+            name: "empty lists",
+            content: "components: []\nvariables: {}\n",
+            expected: Answers{Components: []string{}, Variables: map[string]string{}},
+        },
+        {
+            name: "comments",
+            content: "# answers\nlocation: \"/opt/my#app\" # comment\nvariables:\n  URL: http://host/#anchor\r\n",
+            expected: Answers{Location: "/opt/my#app", Variables: map[string]string{"URL": "http://host/#anchor"}},
+        },
+        {name: "invalid boolean", content: "accept_license: maybe\n", fails: true},
+        {name: "unknown key", content: "licence: true\n", fails: true},
+        {name: "unexpected indentation", content: "location: /opt\n  other: value\n", fails: true},
+        {name: "invalid flow list", content: "components: core, docs\n", fails: true},
+        {name: "missing value separator", content: "components\n", fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            answers, err := parse_yaml_answers(test.content)
+            if test.fails != (err != nil) {
+                t.Fatalf("parse_yaml_answers: %v, expected failure: %t", err, test.fails)
+            }
+            if !test.fails && !reflect.DeepEqual(answers, test.expected) {
+                t.Errorf("answers %+v, expected %+v", answers, test.expected)
+            }
+        })
+    }
+}
+
+/*
+    This function tests that written YAML answer files
+    are parsed to the same answers.
+*/
+func TestFormatYamlAnswers(t *testing.T) {
+    tests := []struct {
+        name string
+        answers Answers
+    }{
+        {name: "defaults", answers: Answers{Components: []string{}}},
+        {
+            name: "all choices",
+            answers: Answers{
+                AcceptLicense: true,
+                Components: []string{"core", "docs"},
+                Location: `C:\Program Files\App`,
+                Variables: map[string]string{"MESSAGE": "say \"hello\" # not a comment", "EMPTY": ""},
+                ServiceStart: "none",
+            },
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            answers, err := parse_yaml_answers(format_yaml_answers(test.answers))
+            if err != nil {
+                t.Fatalf("parse_yaml_answers: %v", err)
+            }
+            if !reflect.DeepEqual(answers, test.answers) {
+                t.Errorf("answers %+v, expected %+v", answers, test.answers)
+            }
+        })
+    }
+}
+
+/*
+    This function tests answer files loading: JSON and
+    YAML files replace choices, components dependencies
+    are resolved and unknown names are usage errors.
+*/
+func TestLoadAnswers(t *testing.T) {
+    manifest := `{
+        "components": [
+            {"name": "core", "required": true},
+            {"name": "web", "default": true, "depends": ["core"]},
+            {"name": "docs"},
+            {"name": "plugins", "depends": ["docs"]}
+        ],
+        "variables": [{"name": "PORT", "default": "80"}]
+    }`
+
+    tests := []struct {
+        name string
+        file string
+        content string
+        components []string
+        port string
+        fails bool
+    }{
+        {name: "json answers", file: "answers.json", content: `{"components": ["plugins"], "variables": {"PORT": "8080"}}`, components: []string{"core", "docs", "plugins"}, port: "8080"},
+        {name: "yaml answers", file: "answers.yaml", content: "components:\n  - plugins\nvariables:\n  PORT: \"8080\"\n", components: []string{"core", "docs", "plugins"}, port: "8080"},
+        {name: "yml extension", file: "answers.YML", content: "components: [docs]\n", components: []string{"core", "docs"}, port: "80"},
+        {name: "components are not answered", file: "answers.yaml", content: "location: /opt/app\n", components: []string{"core", "web"}, port: "80"},
+        {name: "unknown component", file: "answers.yaml", content: "components: [cli]\n", fails: true},
+        {name: "unknown variable", file: "answers.json", content: `{"variables": {"HOST": "localhost"}}`, fails: true},
+        {name: "invalid yaml", file: "answers.yaml", content: "components: {}\n", fails: true},
+        {name: "invalid json", file: "answers.json", content: "components: []", fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), test.file)
+            err := os.WriteFile(path, []byte(test.content), 0600)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            setup := new_test_installer(t, manifest, fstest.MapFS{}, NewMemoryFileSystem())
+            setup.options.Answers = path
+            err = setup.prepare(Receipt{})
+
+            var installer_error *Error
+            if test.fails {
+                if !errors.As(err, &installer_error) || installer_error.Code != ExitUsage {
+                    t.Fatalf("prepare: %v, expected a usage error", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("prepare: %v", err)
+            }
+
+            if components := setup.installed_components(); !reflect.DeepEqual(components, test.components) {
+                t.Errorf("components %v, expected %v", components, test.components)
+            }
+            if setup.variables["PORT"] != test.port {
+                t.Errorf("PORT is %q, expected %q", setup.variables["PORT"], test.port)
+            }
+        })
+    }
+}
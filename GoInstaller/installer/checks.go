@@ -0,0 +1,299 @@
+/*
+    This file implements post-install health checks for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "net/http"
+    "runtime"
+    "context"
+    "strings"
+    "regexp"
+    "errors"
+    "time"
+    "fmt"
+    "net"
+    "os"
+)
+
+/*
+    A post-install check of the manifest "checks":
+
+     - "command": the command exit code is ExitCode and
+       its output matches the Output regular expression
+     - "service": the service is active (the Windows
+       service is the application service by default)
+     - "tcp": the localhost Port accepts connections
+     - "http": a GET on the localhost Port and Path returns 200
+
+    Service, TCP and HTTP checks are retried until the
+    timeout (seconds), a failed check with Rollback rolls
+    back the install. They are skipped when the target is
+    not the OS filesystem, commands use Options.CommandRunner.
+*/
+type HealthCheck struct {
+    Name string `json:"name"`
+    Type string `json:"type"`
+    Command string `json:"command"`
+    ExitCode int `json:"exit_code"`
+    Output string `json:"output"`
+    Service string `json:"service"`
+    Port int `json:"port"`
+    Path string `json:"path"`
+    Timeout int `json:"timeout"`
+    Rollback bool `json:"rollback"`
+}
+
+/*
+    Default time to wait for a check.
+*/
+const default_check_timeout = 10 * time.Second
+
+var check_types = []string{"command", "service", "tcp", "http"}
+
+/*
+    This method checks the manifest health checks
+    before the install.
+*/
+func (installer *Installer) validate_checks() error {
+    for _, check := range installer.manifest.Checks {
+        if !contains(check_types, check.Type) {
+            return failure(ExitPayload, "invalid check type %q (%s)", check.Type, strings.Join(check_types, ", "))
+        }
+
+        if (check.Type == "tcp" || check.Type == "http") && (check.Port <= 0 || check.Port > 65535) {
+            return failure(ExitPayload, "invalid port for check %s: %d", check.label(), check.Port)
+        }
+
+        if check.Type == "command" && check.Command == "" {
+            return failure(ExitPayload, "no command for check %s", check.label())
+        }
+
+        if check.Output != "" {
+            _, err := regexp.Compile(check.Output)
+            if err != nil {
+                return failure(ExitPayload, "invalid output regex for check %s: %v", check.label(), err)
+            }
+        }
+    }
+    return nil
+}
+
+/*
+    This method runs health checks after commands and services,
+    all checks run and failures are printed. When a failed check
+    has Rollback the error is returned (the install is rolled
+    back), otherwise the install is kept and marked as failed.
+*/
+func (installer *Installer) run_checks(ctx context.Context) error {
+    var failed []string
+    rollback := false
+
+    for _, check := range installer.manifest.Checks {
+        err := installer.run_check(ctx, check)
+        if errors.Is(err, Skipped) {
+            fmt.Printf("Health check skipped: %s\n", check.label())
+            continue
+        }
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Health check failed: %s: %v\n", check.label(), err)
+            failed = append(failed, check.label())
+            rollback = rollback || check.Rollback
+            continue
+        }
+        fmt.Printf("Health check passed: %s\n", check.label())
+    }
+
+    if len(failed) == 0 {
+        return nil
+    }
+
+    err := failure(ExitHealthCheck, "health checks failed: %s", strings.Join(failed, ", "))
+    if rollback {
+        return err
+    }
+    installer.checks_error = err
+    return nil
+}
+
+/*
+    This method runs a check with variables values.
+*/
+func (installer *Installer) run_check(ctx context.Context, check HealthCheck) error {
+    timeout := default_check_timeout
+    if check.Timeout > 0 {
+        timeout = time.Duration(check.Timeout) * time.Second
+    }
+
+    check_context, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    if check.Type != "command" && !installer.system_target() {
+        return Skipped
+    }
+
+    switch check.Type {
+    case "command":
+        return installer.check_command(check_context, installer.expand_variables(check.Command), check)
+    case "service":
+        return installer.check_service(check_context, installer.check_service_name(check))
+    case "tcp":
+        return retry_check(check_context, func() error {
+            connection, err := (&net.Dialer{}).DialContext(check_context, "tcp", check.address())
+            if err == nil {
+                connection.Close()
+            }
+            return err
+        })
+    }
+
+    url := "http://" + check.address() + "/" + strings.TrimPrefix(installer.expand_variables(check.Path), "/")
+    return retry_check(check_context, func() error {
+        request, err := http.NewRequestWithContext(check_context, http.MethodGet, url, nil)
+        if err != nil {
+            return err
+        }
+
+        response, err := http.DefaultClient.Do(request)
+        if err != nil {
+            return err
+        }
+        response.Body.Close()
+
+        if response.StatusCode != http.StatusOK {
+            return fmt.Errorf("GET %s: %s", url, response.Status)
+        }
+        return nil
+    })
+}
+
+/*
+    This method runs a check command and compares its
+    exit code and its output.
+*/
+func (installer *Installer) check_command(ctx context.Context, command string, check HealthCheck) error {
+    out, err := installer.commands.Run(ctx, command, installer.variables_environment())
+    code, ok := exit_code(err)
+    if !ok {
+        return err
+    }
+
+    if ctx.Err() != nil {
+        return ctx.Err()
+    }
+
+    if code != check.ExitCode {
+        return fmt.Errorf("exit code %d (expected %d): %s", code, check.ExitCode, strings.TrimSpace(string(out)))
+    }
+
+    if check.Output != "" && !regexp.MustCompile(check.Output).Match(out) {
+        return fmt.Errorf("output does not match %q: %s", check.Output, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
+
+/*
+    This method waits for an active service.
+*/
+func (installer *Installer) check_service(ctx context.Context, name string) error {
+    return retry_check(ctx, func() error {
+        active, err := installer.services.Active(ctx, name)
+        if err == nil && !active {
+            err = fmt.Errorf("service %s is not active", name)
+        }
+        return err
+    })
+}
+
+/*
+    This method returns the service name of a check.
+*/
+func (installer *Installer) check_service_name(check HealthCheck) string {
+    if check.Service != "" {
+        return installer.expand_variables(check.Service)
+    }
+    if runtime.GOOS == "windows" {
+        return installer.name
+    }
+    return installer.name + ".service"
+}
+
+/*
+    This function calls a check until it succeeds or the
+    context is done, the last check error is returned.
+*/
+func retry_check(ctx context.Context, check func() error) error {
+    for {
+        err := check()
+        if err == nil {
+            return nil
+        }
+
+        select {
+        case <-time.After(500 * time.Millisecond):
+        case <-ctx.Done():
+            return err
+        }
+    }
+}
+
+/*
+    This method returns the localhost address of a check.
+*/
+func (check HealthCheck) address() string {
+    return net.JoinHostPort("localhost", fmt.Sprint(check.Port))
+}
+
+/*
+    This method returns the check name or its target.
+*/
+func (check HealthCheck) label() string {
+    if check.Name != "" {
+        return check.Name
+    }
+
+    switch check.Type {
+    case "command":
+        return check.Command
+    case "service":
+        return strings.TrimSpace("service " + check.Service)
+    case "http":
+        return fmt.Sprintf("http %d%s", check.Port, check.Path)
+    }
+    return fmt.Sprintf("%s %d", check.Type, check.Port)
+}
+
+/*
+    This method returns health checks.
+*/
+func (installer *Installer) plan_checks(ctx context.Context) ([]Action, error) {
+    var actions []Action
+    for _, check := range installer.manifest.Checks {
+        target := check.address()
+        switch check.Type {
+        case "command":
+            target = installer.expand_variables(check.Command)
+        case "service":
+            target = installer.check_service_name(check)
+        case "http":
+            target = "http://" + target + "/" + strings.TrimPrefix(installer.expand_variables(check.Path), "/")
+        }
+        actions = append(actions, Action{Kind: "check", Path: check.Type, Source: target})
+    }
+    return actions, nil
+}
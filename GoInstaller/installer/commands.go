@@ -0,0 +1,111 @@
+/*
+    This file implements commands execution for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "context"
+    "os/exec"
+    "runtime"
+    "errors"
+    "os"
+)
+
+/*
+    Skipped is returned by a command runner (or a check)
+    not executed because the target is not the system.
+*/
+var Skipped = errors.New("skipped for this install target")
+
+/*
+    The runner of post-install commands, custom steps
+    commands and health check commands. The output is
+    returned, a failed command returns an error with an
+    ExitCode method (like *exec.ExitError).
+*/
+type CommandRunner interface {
+    Run(ctx context.Context, command string, environment []string) ([]byte, error)
+}
+
+/*
+    The system shell: sh on Linux and cmd on Windows.
+*/
+type system_command_runner struct{}
+
+/*
+    A command runner executing nothing, it's used when
+    the target is not the OS filesystem.
+*/
+type NoCommandRunner struct{}
+
+/*
+    This method runs a shell command line with variables
+    in its environment, the output is returned.
+*/
+func (system_command_runner) Run(ctx context.Context, command string, environment []string) ([]byte, error) {
+    var cmd *exec.Cmd
+    if runtime.GOOS == "windows" {
+        cmd = execute_windows_command(ctx, command)
+    } else {
+        cmd = exec.CommandContext(ctx, "sh", "-c", command)
+    }
+    cmd.Env = append(os.Environ(), environment...)
+    return cmd.CombinedOutput()
+}
+
+/*
+    This method returns Skipped: no command is executed.
+*/
+func (NoCommandRunner) Run(ctx context.Context, command string, environment []string) ([]byte, error) {
+    return nil, Skipped
+}
+
+/*
+    This function returns the exit code of a command error,
+    ok is false when the command has not been executed.
+*/
+func exit_code(err error) (int, bool) {
+    if err == nil {
+        return 0, true
+    }
+
+    var exit interface{ ExitCode() int }
+    if errors.As(err, &exit) {
+        return exit.ExitCode(), true
+    }
+    return 0, false
+}
+
+/*
+    This method checks if the install target is the
+    system: commands, network probes and services
+    are used only for the OS filesystem.
+*/
+func (installer *Installer) system_target() bool {
+    _, ok := installer.target.(OSFileSystem)
+    return ok
+}
+
+/*
+    This method checks if Windows settings (registry,
+    start menu, services and event log) are configured:
+    only on Windows when the target is the system.
+*/
+func (installer *Installer) windows_system() bool {
+    return runtime.GOOS == "windows" && installer.system_target()
+}
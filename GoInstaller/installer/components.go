@@ -0,0 +1,243 @@
+/*
+    This file implements optional components for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path"
+    "sort"
+    "fmt"
+)
+
+type Component struct {
+    Name string `json:"name"`
+    Description string `json:"description"`
+    Required bool `json:"required"`
+    Default bool `json:"default"`
+    Depends []string `json:"depends"`
+    Files []string `json:"files"`
+    LinuxCommands []string `json:"linux_commands"`
+    WindowsCommands []string `json:"windows_commands"`
+}
+
+/*
+    This method selects components: required components,
+    components installed by the previous install and new
+    components of this manifest with their default (or
+    default components for a new install), then Options.With
+    and Options.Without.
+*/
+func (installer *Installer) select_components() error {
+    if len(installer.manifest.Components) == 0 {
+        return nil
+    }
+
+    previous := installer.previous_receipt
+    known := previous.ManifestComponents
+    if known == nil {
+        known = previous.Components
+    }
+
+    for _, component := range installer.manifest.Components {
+        if previous.Components == nil || !contains(known, component.Name) {
+            installer.selected_components[component.Name] = component.Default
+        } else {
+            installer.selected_components[component.Name] = contains(previous.Components, component.Name)
+        }
+    }
+
+    for _, name := range installer.options.With {
+        if installer.find_component(name) == nil {
+            return failure(ExitUsage, "unknown component: %s", name)
+        }
+        installer.selected_components[name] = true
+    }
+
+    for _, name := range installer.options.Without {
+        component := installer.find_component(name)
+        if component == nil {
+            return failure(ExitUsage, "unknown component: %s", name)
+        }
+        if component.Required {
+            return failure(ExitUsage, "component %s is required", name)
+        }
+        installer.selected_components[name] = false
+    }
+
+    err := installer.resolve_dependencies(installer.options.Without)
+    if err != nil {
+        return &Error{Code: ExitUsage, Err: err}
+    }
+    return nil
+}
+
+/*
+    This method selects required components and dependencies
+    of selected components, excluded components can't be
+    selected as a dependency.
+*/
+func (installer *Installer) resolve_dependencies(excluded []string) error {
+    for _, component := range installer.manifest.Components {
+        if component.Required {
+            installer.selected_components[component.Name] = true
+        }
+    }
+
+    for changed := true; changed; {
+        changed = false
+        for _, component := range installer.manifest.Components {
+            if !installer.selected_components[component.Name] {
+                continue
+            }
+
+            for _, dependency := range component.Depends {
+                if installer.find_component(dependency) == nil {
+                    return fmt.Errorf("component %s depends on unknown component %s", component.Name, dependency)
+                }
+                if contains(excluded, dependency) {
+                    return fmt.Errorf("component %s requires %s", component.Name, dependency)
+                }
+                if !installer.selected_components[dependency] {
+                    installer.selected_components[dependency] = true
+                    changed = true
+                }
+            }
+        }
+    }
+    return nil
+}
+
+/*
+    This method returns the component by name.
+*/
+func (installer *Installer) find_component(name string) *Component {
+    for index := range installer.manifest.Components {
+        if installer.manifest.Components[index].Name == name {
+            return &installer.manifest.Components[index]
+        }
+    }
+    return nil
+}
+
+/*
+    This method returns the component of a payload file
+    ("category/name"), the first component with a matching
+    pattern. Files without component are always installed.
+*/
+func (installer *Installer) file_component(name string) string {
+    for _, component := range installer.manifest.Components {
+        for _, pattern := range component.Files {
+            if matched, _ := path.Match(pattern, name); matched {
+                return component.Name
+            }
+        }
+    }
+    return ""
+}
+
+/*
+    This method checks if files of a component are installed.
+*/
+func (installer *Installer) component_selected(name string) bool {
+    return name == "" || installer.selected_components[name]
+}
+
+/*
+    This method returns sorted names of installed components.
+*/
+func (installer *Installer) installed_components() []string {
+    names := []string{}
+    for _, component := range installer.manifest.Components {
+        if installer.selected_components[component.Name] {
+            names = append(names, component.Name)
+        }
+    }
+    sort.Strings(names)
+    return names
+}
+
+/*
+    This method returns commands of installed
+    components for an operating system.
+*/
+func (installer *Installer) component_commands(goos string) []string {
+    var commands []string
+    for _, component := range installer.manifest.Components {
+        if !installer.selected_components[component.Name] {
+            continue
+        }
+
+        if goos == "windows" {
+            commands = append(commands, component.WindowsCommands...)
+        } else {
+            commands = append(commands, component.LinuxCommands...)
+        }
+    }
+    return commands
+}
+
+/*
+    This method returns sorted names of the manifest
+    components, components missing from the previous
+    install manifest are new components.
+*/
+func (installer *Installer) manifest_components() []string {
+    var names []string
+    for _, component := range installer.manifest.Components {
+        names = append(names, component.Name)
+    }
+    sort.Strings(names)
+    return names
+}
+
+/*
+    This method removes files of components installed
+    by the previous install and unselected now.
+*/
+func (installer *Installer) remove_unselected_components() {
+    for _, entry := range installer.unselected_files() {
+        installer.remove_receipt_entry(entry)
+    }
+}
+
+/*
+    This method returns files of components installed by
+    the previous install and unselected now, in reverse order.
+*/
+func (installer *Installer) unselected_files() []ReceiptFile {
+    var entries []ReceiptFile
+    for index := len(installer.previous_receipt.Files) - 1; index >= 0; index-- {
+        entry := installer.previous_receipt.Files[index]
+        if entry.Component != "" && !installer.selected_components[entry.Component] && !installer.receipt_contains(entry.Path) {
+            entries = append(entries, entry)
+        }
+    }
+    return entries
+}
+
+/*
+    This function checks if a list contains a value.
+*/
+func contains(values []string, value string) bool {
+    for _, element := range values {
+        if element == value {
+            return true
+        }
+    }
+    return false
+}
@@ -0,0 +1,142 @@
+/*
+    This file tests components for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "reflect"
+    "testing"
+    "errors"
+)
+
+/*
+    This function tests the components selection: defaults
+    of a new install, components of the previous install,
+    new components of an upgrade, required components,
+    dependencies and Options.With and Options.Without.
+*/
+func TestSelectComponents(t *testing.T) {
+    manifest := `{"components": [
+        {"name": "core", "required": true},
+        {"name": "web", "default": true, "depends": ["core"]},
+        {"name": "docs"},
+        {"name": "plugins", "depends": ["docs"]},
+        {"name": "metrics", "default": true}
+    ]}`
+
+    tests := []struct {
+        name string
+        previous Receipt
+        with []string
+        without []string
+        expected []string
+        fails bool
+    }{
+        {name: "new install defaults", expected: []string{"core", "metrics", "web"}},
+        {
+            name: "previous selection is kept",
+            previous: Receipt{Components: []string{"core", "docs"}, ManifestComponents: []string{"core", "docs", "metrics", "plugins", "web"}},
+            expected: []string{"core", "docs"},
+        },
+        {
+            name: "new component default",
+            previous: Receipt{Components: []string{"core", "docs"}, ManifestComponents: []string{"core", "docs", "plugins", "web"}},
+            expected: []string{"core", "docs", "metrics"},
+        },
+        {
+            name: "receipt without manifest components",
+            previous: Receipt{Components: []string{"core", "web"}},
+            expected: []string{"core", "metrics", "web"},
+        },
+        {
+            name: "receipt without components",
+            previous: Receipt{Components: []string{}, ManifestComponents: []string{"core", "docs", "metrics", "plugins", "web"}},
+            expected: []string{"core"},
+        },
+        {name: "with a dependency", with: []string{"plugins"}, expected: []string{"core", "docs", "metrics", "plugins", "web"}},
+        {name: "without a default", without: []string{"web", "metrics"}, expected: []string{"core"}},
+        {
+            name: "without a previous component",
+            previous: Receipt{Components: []string{"core", "docs"}, ManifestComponents: []string{"core", "docs", "metrics", "plugins", "web"}},
+            without: []string{"docs"},
+            expected: []string{"core"},
+        },
+        {name: "without a required component", without: []string{"core"}, fails: true},
+        {name: "without a dependency", with: []string{"plugins"}, without: []string{"docs"}, fails: true},
+        {name: "unknown with", with: []string{"cli"}, fails: true},
+        {name: "unknown without", without: []string{"cli"}, fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            setup := new_test_installer(t, manifest, fstest.MapFS{}, NewMemoryFileSystem())
+            setup.options.With = test.with
+            setup.options.Without = test.without
+            setup.previous_receipt = test.previous
+            err := setup.select_components()
+
+            var installer_error *Error
+            if test.fails {
+                if !errors.As(err, &installer_error) || installer_error.Code != ExitUsage {
+                    t.Fatalf("select_components: %v, expected a usage error", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("select_components: %v", err)
+            }
+
+            if components := setup.installed_components(); !reflect.DeepEqual(components, test.expected) {
+                t.Errorf("components %v, expected %v", components, test.expected)
+            }
+        })
+    }
+}
+
+/*
+    This function tests the component of payload files:
+    the first matching pattern, files without component
+    are always installed.
+*/
+func TestFileComponent(t *testing.T) {
+    manifest := `{"components": [
+        {"name": "docs", "files": ["data/*.md"]},
+        {"name": "all", "files": ["data/*", "program/tool"]}
+    ]}`
+
+    tests := []struct {
+        name string
+        file string
+        expected string
+    }{
+        {name: "first pattern", file: "data/README.md", expected: "docs"},
+        {name: "second pattern", file: "data/state.db", expected: "all"},
+        {name: "file name", file: "program/tool", expected: "all"},
+        {name: "no component", file: "program/app", expected: ""},
+    }
+
+    setup := new_test_installer(t, manifest, fstest.MapFS{}, NewMemoryFileSystem())
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            if component := setup.file_component(test.file); component != test.expected {
+                t.Errorf("component %q, expected %q", component, test.expected)
+            }
+        })
+    }
+}
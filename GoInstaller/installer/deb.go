@@ -0,0 +1,299 @@
+/*
+    This file implements the Debian package export for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// installer export deb package.deb
+
+package installer
+
+import (
+    "compress/gzip"
+    "encoding/hex"
+    "archive/tar"
+    "crypto/md5"
+    "strings"
+    "bytes"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+type ArchiveMember struct {
+    name string
+    content string
+    mode os.FileMode
+}
+
+/*
+    Debian names of Go architectures, other names are the same.
+*/
+var debian_architectures = map[string]string{
+    "386": "i386",
+    "arm": "armhf",
+    "ppc64le": "ppc64el",
+    "mipsle": "mipsel",
+    "mips64le": "mips64el",
+}
+
+/*
+    This method writes a Debian package: an ar archive
+    with the debian-binary, control.tar.gz and data.tar.gz
+    members. Maintainer scripts run the Linux commands and
+    manage systemd units, config files are conffiles. Data
+    files are created by postinst only when they are missing.
+*/
+func (installer *Installer) write_deb(output string) error {
+    files, defaults := installer.stage_data_files(installer.package_files())
+    modified := package_time()
+
+    data, err := os.CreateTemp("", "goinstaller-*.tar.gz")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(data.Name())
+    defer data.Close()
+
+    sums, size, err := write_deb_data(data, files, modified)
+    if err != nil {
+        return err
+    }
+
+    control, err := installer.write_deb_control(files, defaults, sums, size, modified)
+    if err != nil {
+        return err
+    }
+
+    data_size, err := data.Seek(0, io.SeekCurrent)
+    if err == nil {
+        _, err = data.Seek(0, io.SeekStart)
+    }
+    if err != nil {
+        return err
+    }
+
+    file, err := os.Create(output)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    _, err = file.WriteString("!<arch>\n")
+    if err == nil {
+        err = write_ar_member(file, "debian-binary", modified, strings.NewReader("2.0\n"), 4)
+    }
+    if err == nil {
+        err = write_ar_member(file, "control.tar.gz", modified, bytes.NewReader(control), int64(len(control)))
+    }
+    if err == nil {
+        err = write_ar_member(file, "data.tar.gz", modified, data, data_size)
+    }
+    if err != nil {
+        return err
+    }
+    return file.Close()
+}
+
+/*
+    This function writes an ar archive member, members
+    are aligned on 2 bytes.
+*/
+func write_ar_member(writer io.Writer, name string, modified time.Time, content io.Reader, size int64) error {
+    _, err := fmt.Fprintf(writer, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", name, modified.Unix(), 0, 0, "100644", size)
+    if err != nil {
+        return err
+    }
+
+    _, err = io.CopyN(writer, content, size)
+    if err == nil && size % 2 == 1 {
+        _, err = writer.Write([]byte("\n"))
+    }
+    return err
+}
+
+/*
+    This function writes the data.tar.gz archive and returns
+    the md5sums file content and the installed size.
+*/
+func write_deb_data(writer io.Writer, files []PackageFile, modified time.Time) (string, int64, error) {
+    compressor := gzip.NewWriter(writer)
+    archive := tar.NewWriter(compressor)
+    seen := make(map[string]bool)
+
+    var sums strings.Builder
+    var size int64
+    for _, file := range files {
+        for _, parent := range missing_parents(file.path, seen) {
+            err := archive.WriteHeader(deb_header(parent + "/", tar.TypeDir, 0755, 0, modified))
+            if err != nil {
+                return "", 0, err
+            }
+        }
+
+        if file.directory {
+            if !seen[file.path] {
+                seen[file.path] = true
+                err := archive.WriteHeader(deb_header(file.path + "/", tar.TypeDir, file.mode, 0, modified))
+                if err != nil {
+                    return "", 0, err
+                }
+            }
+            continue
+        }
+
+        if file.link != "" {
+            header := deb_header(file.path, tar.TypeSymlink, file.mode, 0, modified)
+            header.Linkname = file.link
+            err := archive.WriteHeader(header)
+            if err != nil {
+                return "", 0, err
+            }
+            continue
+        }
+
+        err := archive.WriteHeader(deb_header(file.path, tar.TypeReg, file.mode, file.size, modified))
+        if err != nil {
+            return "", 0, err
+        }
+
+        hash, err := copy_package_file(archive, file)
+        if err != nil {
+            return "", 0, err
+        }
+
+        sums.WriteString(hex.EncodeToString(hash) + "  " + strings.TrimPrefix(file.path, "/") + "\n")
+        size += file.size
+    }
+
+    err := archive.Close()
+    if err == nil {
+        err = compressor.Close()
+    }
+    return sums.String(), size, err
+}
+
+/*
+    This function copies a packaged file in an archive
+    and returns its MD5 hash.
+*/
+func copy_package_file(writer io.Writer, file PackageFile) ([]byte, error) {
+    source, err := file.open()
+    if err != nil {
+        return nil, err
+    }
+    defer source.Close()
+
+    hasher := md5.New()
+    _, err = io.CopyBuffer(io.MultiWriter(writer, hasher), source, make([]byte, copy_buffer_size))
+    return hasher.Sum(nil), err
+}
+
+/*
+    This function returns a tar header owned by root,
+    names are relative to the root directory.
+*/
+func deb_header(name string, typeflag byte, mode os.FileMode, size int64, modified time.Time) *tar.Header {
+    return &tar.Header{
+        Typeflag: typeflag,
+        Name: "." + name,
+        Mode: int64(mode.Perm()),
+        Size: size,
+        Uname: "root",
+        Gname: "root",
+        ModTime: modified,
+    }
+}
+
+/*
+    This method writes the control.tar.gz archive: control,
+    md5sums, conffiles and maintainer scripts.
+*/
+func (installer *Installer) write_deb_control(files []PackageFile, defaults string, sums string, size int64, modified time.Time) ([]byte, error) {
+    architecture := installer.package_architecture(debian_architectures)
+
+    maintainer := installer.manifest.Maintainer
+    if maintainer == "" {
+        maintainer = installer.name + " <root@localhost>"
+    }
+
+    control := "Package: " + installer.package_name() + "\n" +
+        "Version: " + installer.package_version() + "\n" +
+        "Architecture: " + architecture + "\n" +
+        "Maintainer: " + maintainer + "\n" +
+        fmt.Sprintf("Installed-Size: %d\n", (size + 1023) / 1024) +
+        "Section: misc\n" +
+        "Priority: optional\n" +
+        "Description: " + strings.ReplaceAll(strings.TrimSpace(installer.package_description()), "\n", "\n ") + "\n"
+
+    var conffiles string
+    for _, file := range files {
+        if file.config {
+            conffiles += file.path + "\n"
+        }
+    }
+
+    units := package_units(files)
+    members := []ArchiveMember{
+        {"control", control, 0644},
+        {"md5sums", sums, 0644},
+        {"conffiles", conffiles, 0644},
+        {"postinst", deb_script("configure", defaults + installer.post_install_script(units)), 0755},
+        {"prerm", deb_script("remove", pre_remove_script(units)), 0755},
+    }
+
+    if len(units) > 0 {
+        members = append(members, ArchiveMember{"postrm", deb_script("remove", "systemctl daemon-reload || true\n"), 0755})
+    }
+
+    var buffer bytes.Buffer
+    compressor := gzip.NewWriter(&buffer)
+    archive := tar.NewWriter(compressor)
+
+    err := archive.WriteHeader(deb_header("/", tar.TypeDir, 0755, 0, modified))
+    for _, member := range members {
+        if err != nil {
+            return nil, err
+        }
+        if member.content == "" {
+            continue
+        }
+
+        err = archive.WriteHeader(deb_header("/" + member.name, tar.TypeReg, member.mode, int64(len(member.content)), modified))
+        if err == nil {
+            _, err = archive.Write([]byte(member.content))
+        }
+    }
+
+    if err == nil {
+        err = archive.Close()
+    }
+    if err == nil {
+        err = compressor.Close()
+    }
+    return buffer.Bytes(), err
+}
+
+/*
+    This function returns a maintainer script running
+    the body for an action, empty for an empty body.
+*/
+func deb_script(action string, body string) string {
+    if body == "" {
+        return ""
+    }
+    return "#!/bin/sh\nif [ \"$1\" = \"" + action + "\" ]; then\n" + body + "fi\n"
+}
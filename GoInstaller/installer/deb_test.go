@@ -0,0 +1,196 @@
+/*
+    This file tests Debian packages for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "path/filepath"
+    "compress/gzip"
+    "encoding/hex"
+    "archive/tar"
+    "crypto/md5"
+    "strconv"
+    "context"
+    "strings"
+    "runtime"
+    "testing"
+    "bytes"
+    "io"
+    "os"
+)
+
+/*
+    This function exports the package of a test payload
+    and returns the package file content.
+*/
+func export_test_package(t *testing.T, manifest string, payload fstest.MapFS, format string) (*Installer, string) {
+    t.Helper()
+    setup := new_test_installer(t, manifest, payload, NewMemoryFileSystem())
+    output := filepath.Join(t.TempDir(), "package." + format)
+    err := setup.Export(context.Background(), format, output)
+    if err != nil {
+        t.Fatalf("Export %s: %v", format, err)
+    }
+    return setup, output
+}
+
+/*
+    This function returns members of an ar archive
+    by name, in the archive order.
+*/
+func read_ar_members(t *testing.T, content []byte) ([]string, map[string][]byte) {
+    t.Helper()
+    if !bytes.HasPrefix(content, []byte("!<arch>\n")) {
+        t.Fatalf("invalid ar magic: %q", content[:8])
+    }
+
+    var names []string
+    members := make(map[string][]byte)
+    for offset := 8; offset < len(content); {
+        if offset + 60 > len(content) || string(content[offset + 58:offset + 60]) != "`\n" {
+            t.Fatalf("invalid ar member header at %d", offset)
+        }
+
+        name := strings.TrimSpace(string(content[offset:offset + 16]))
+        size, err := strconv.Atoi(strings.TrimSpace(string(content[offset + 48:offset + 58])))
+        if err != nil || offset + 60 + size > len(content) {
+            t.Fatalf("invalid ar member size for %s", name)
+        }
+
+        names = append(names, name)
+        members[name] = content[offset + 60:offset + 60 + size]
+        offset += 60 + size + size % 2
+    }
+    return names, members
+}
+
+/*
+    This function returns files of a tar.gz archive by
+    name (directories have a trailing slash).
+*/
+func read_tar_gz(t *testing.T, content []byte) map[string]string {
+    t.Helper()
+    decompressor, err := gzip.NewReader(bytes.NewReader(content))
+    if err != nil {
+        t.Fatalf("invalid gzip archive: %v", err)
+    }
+
+    files := make(map[string]string)
+    archive := tar.NewReader(decompressor)
+    for {
+        header, err := archive.Next()
+        if err == io.EOF {
+            return files
+        }
+        if err != nil {
+            t.Fatalf("invalid tar archive: %v", err)
+        }
+
+        data, err := io.ReadAll(archive)
+        if err != nil {
+            t.Fatalf("reading %s: %v", header.Name, err)
+        }
+        files[header.Name] = string(data)
+    }
+}
+
+/*
+    This function tests the Debian package structure: ar
+    members, control fields with the architecture of the
+    manifest, conffiles, md5sums and data files staged in
+    the default data directory and copied by postinst.
+*/
+func TestWriteDeb(t *testing.T) {
+    host := runtime.GOARCH
+    if name, ok := debian_architectures[host]; ok {
+        host = name
+    }
+
+    tests := []struct {
+        name string
+        architecture string
+        expected string
+    }{
+        {name: "build architecture", expected: host},
+        {name: "debian name", architecture: "386", expected: "i386"},
+        {name: "same name", architecture: "arm64", expected: "arm64"},
+        {name: "architecture independent", architecture: "all", expected: "all"},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            payload := fstest.MapFS{
+                "program/app": test_file("binary"),
+                "config/app.conf": test_file("key=value"),
+                "data/state.db": test_file("state"),
+            }
+            setup, output := export_test_package(t, `{"architecture": "` + test.architecture + `"}`, payload, "deb")
+
+            content, err := os.ReadFile(output)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            names, members := read_ar_members(t, content)
+            if strings.Join(names, " ") != "debian-binary control.tar.gz data.tar.gz" {
+                t.Fatalf("ar members: %v", names)
+            }
+            if string(members["debian-binary"]) != "2.0\n" {
+                t.Errorf("debian-binary: %q", members["debian-binary"])
+            }
+
+            control := read_tar_gz(t, members["control.tar.gz"])
+            data := read_tar_gz(t, members["data.tar.gz"])
+            if !strings.Contains(control["./control"], "\nArchitecture: " + test.expected + "\n") {
+                t.Errorf("control file, expected architecture %s:\n%s", test.expected, control["./control"])
+            }
+
+            layout := setup.os_layout("linux")
+            config := filepath.ToSlash(filepath.Join(layout.Config, "app.conf"))
+            if !strings.Contains(control["./conffiles"], config + "\n") {
+                t.Errorf("%s is not in conffiles: %q", config, control["./conffiles"])
+            }
+            if data["." + config] != "key=value" {
+                t.Errorf("%s is not in data.tar.gz", config)
+            }
+
+            state := filepath.ToSlash(filepath.Join(layout.Data, "state.db"))
+            staged := "/usr/share/" + setup.package_name() + "/default-data" + state
+            if _, ok := data["." + state]; ok {
+                t.Errorf("the data file %s is packaged, dpkg would replace it", state)
+            }
+            if data["." + staged] != "state" {
+                t.Errorf("the data file is not staged in %s", staged)
+            }
+            if line := "[ -e " + shell_quote(state) + " ] || cp -p " + shell_quote(staged) + " " + shell_quote(state) + "\n"; !strings.Contains(control["./postinst"], line) {
+                t.Errorf("postinst doesn't copy the data file:\n%s", control["./postinst"])
+            }
+
+            for name := range data {
+                if !strings.HasPrefix(name, "./") {
+                    t.Errorf("%s is not relative to the root directory", name)
+                }
+            }
+            sum := md5.Sum([]byte("key=value"))
+            if !strings.Contains(control["./md5sums"], hex.EncodeToString(sum[:]) + "  " + strings.TrimPrefix(config, "/") + "\n") {
+                t.Errorf("md5sums: %q", control["./md5sums"])
+            }
+        })
+    }
+}
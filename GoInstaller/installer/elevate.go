@@ -0,0 +1,159 @@
+/*
+    This file implements privileges elevation for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "os/exec"
+    "runtime"
+    "strings"
+    "fmt"
+    "os"
+)
+
+/*
+    Environment variable set in the elevated installer,
+    an elevated installer is never elevated again.
+*/
+const elevated_variable = "GOINSTALLER_ELEVATED"
+
+/*
+    Environment variables passed to the elevated
+    installer, the elevation tool resets others.
+*/
+var elevation_environment = []string{
+    "LANG", "LANGUAGE", "LC_ALL", "LC_MESSAGES", "TERM", "TZ",
+    "HTTP_PROXY", "HTTPS_PROXY", "NO_PROXY",
+    "http_proxy", "https_proxy", "no_proxy",
+}
+
+/*
+    This method re-executes the installer with privileges
+    (sudo, doas or pkexec on Linux) with the original
+    arguments, working directory and allowed environment
+    variables (elevation_environment). It explains what
+    requires privileges and asks a confirmation on a
+    terminal (except with Options.Yes), without terminal
+    the command to run is printed and ExitPrivileges is
+    returned. The elevated installer exit code is returned
+    in the error.
+*/
+func (installer *Installer) Elevate(arguments []string) error {
+    if os.Getenv(elevated_variable) != "" {
+        return failure(ExitPrivileges, "this software installer requires privileges: elevation failed")
+    }
+
+    executable, err := os.Executable()
+    if err != nil {
+        return failure(ExitPrivileges, "this software installer requires privileges: %v", err)
+    }
+
+    directory, err := os.Getwd()
+    if err != nil {
+        directory = "/"
+    }
+
+    command, err := elevation_command(executable, directory, arguments, installer.elevation_environment())
+    if err != nil {
+        return failure(ExitPrivileges, "this software installer requires privileges: %v", err)
+    }
+
+    fmt.Fprintf(os.Stderr, "This software installer requires privileges to:\n")
+    for _, reason := range installer.privileges_reasons() {
+        fmt.Fprintf(os.Stderr, " - %s\n", reason)
+    }
+
+    tool := filepath.Base(command[0])
+    if !is_terminal() {
+        fmt.Fprintf(os.Stderr, "Run: %s\n", strings.Join(append([]string{tool, executable}, arguments...), " "))
+        return failure(ExitPrivileges, "this software installer requires privileges")
+    }
+
+    if !installer.options.Yes && !installer.confirm("Run the installer with " + tool + "?", true) {
+        return failure(ExitPrivileges, "this software installer requires privileges")
+    }
+
+    cmd := exec.Command(command[0], command[1:]...)
+    cmd.Stdin = os.Stdin
+    cmd.Stdout = os.Stdout
+    cmd.Stderr = os.Stderr
+
+    err = cmd.Run()
+    if exit, ok := err.(*exec.ExitError); ok {
+        code := exit.ExitCode()
+        if code <= 0 {
+            code = ExitPrivileges
+        }
+        return failure(code, "elevated installer (%s): %v", tool, err)
+    } else if err != nil {
+        return failure(ExitPrivileges, "running %s: %v", tool, err)
+    }
+    return nil
+}
+
+/*
+    This method returns allowed environment variables
+    of the elevated installer ("NAME=value").
+*/
+func (installer *Installer) elevation_environment() []string {
+    environment := []string{elevated_variable + "=1"}
+    for _, name := range elevation_environment {
+        value, ok := os.LookupEnv(name)
+        if ok {
+            environment = append(environment, name + "=" + value)
+        }
+    }
+    return environment
+}
+
+/*
+    This method returns what requires privileges: install
+    directories, programs in the PATH, services, commands
+    and the install lock.
+*/
+func (installer *Installer) privileges_reasons() []string {
+    layout := installer.get_layout()
+
+    var directories []string
+    for _, category := range categories {
+        directory := installer.category_directory(layout, category)
+        if directory != "" && !contains(directories, directory) {
+            directories = append(directories, directory)
+        }
+    }
+    reasons := []string{"write and remove files in " + strings.Join(directories, ", ")}
+
+    if runtime.GOOS != "windows" && len(installer.manifest.Commands) > 0 {
+        if installer.manifest.PathMode == "profile" {
+            reasons = append(reasons, "add programs to the PATH in " + filepath.Join("/etc/profile.d", installer.name + ".sh"))
+        } else {
+            reasons = append(reasons, "link programs in " + linux_binaries_directory)
+        }
+    }
+
+    if installer.category_has_files("service") {
+        reasons = append(reasons, "install, stop and start services")
+    }
+
+    commands := installer.install_commands(runtime.GOOS)
+    if len(commands) > 0 {
+        reasons = append(reasons, fmt.Sprintf("run post-install commands (%d)", len(commands)))
+    }
+    return append(reasons, "lock installations in " + filepath.Dir(lock_path(installer.name)))
+}
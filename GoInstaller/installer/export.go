@@ -0,0 +1,402 @@
+/*
+    This file implements packages export for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "runtime"
+    "context"
+    "strings"
+    "sort"
+    "path"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+type PackageFile struct {
+    path string
+    mode os.FileMode
+    size int64
+    link string
+    directory bool
+    config bool
+    category string
+    open func() (io.ReadCloser, error)
+}
+
+type counting_writer struct {
+    size int64
+}
+
+/*
+    This method counts written bytes.
+*/
+func (writer *counting_writer) Write(data []byte) (int, error) {
+    writer.size += int64(len(data))
+    return len(data), nil
+}
+
+/*
+    Packages writers by format name.
+*/
+var package_formats = map[string]func(*Installer, string) error{
+    "deb": (*Installer).write_deb,
+    "rpm": (*Installer).write_rpm,
+    "tar": (*Installer).write_tarball,
+    "layer": (*Installer).write_layer_tarball,
+    "oci": (*Installer).write_oci_image,
+}
+
+/*
+    This method exports the payload as a native package (deb,
+    rpm or tar), an OCI layer or an OCI image layout, choices
+    are defaults of the manifest or an answer file (components,
+    location, variables and services policy). The build host
+    install is never used.
+*/
+func (installer *Installer) Export(ctx context.Context, format string, output string) error {
+    writer, ok := package_formats[format]
+    if !ok {
+        return failure(ExitUsage, "unknown package format: %s", format)
+    }
+
+    err := installer.prepare(Receipt{})
+    if err == nil {
+        err = installer.check_service_start()
+    }
+    if err == nil {
+        err = ctx.Err()
+    }
+    if err != nil {
+        return err
+    }
+
+    err = writer(installer, output)
+    if err != nil {
+        return failure(ExitWrite, "writing package %s: %v", output, err)
+    }
+    return nil
+}
+
+/*
+    This method returns packaged files with their
+    destination in the Linux layout: directories,
+    selected payload files and programs links or
+    the PATH profile script. As in the installer,
+    the last file written to a destination is kept.
+*/
+func (installer *Installer) package_files() []PackageFile {
+    layout := installer.os_layout("linux")
+    var files []PackageFile
+
+    directories := []string{layout.Data, layout.Log}
+    for _, category := range categories {
+        directories = append(directories, installer.category_directory(layout, category))
+    }
+
+    seen := make(map[string]bool)
+    for _, directory := range directories {
+        directory = filepath.ToSlash(directory)
+        if !seen[directory] {
+            seen[directory] = true
+            files = append(files, PackageFile{path: directory, mode: 0755, directory: true})
+        }
+    }
+
+    written := make(map[string]int)
+    for _, category := range categories {
+        if !installer.category_selected(category) {
+            continue
+        }
+
+        entries, err := installer.payload_entries(category)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error reading embedded files (%s): %v\n", category, err)
+            continue
+        }
+
+        for _, entry := range entries {
+            name := category + "/" + entry.Name()
+            if entry.IsDir() || !installer.component_selected(installer.file_component(name)) {
+                continue
+            }
+
+            information, err := entry.Info()
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error reading embedded file %s: %v\n", name, err)
+                continue
+            }
+
+            destination := path.Join(filepath.ToSlash(installer.category_directory(layout, category)), entry.Name())
+            if index, ok := written[destination]; ok {
+                files = append(files[:index], files[index + 1:]...)
+                for other, position := range written {
+                    if position > index {
+                        written[other] = position - 1
+                    }
+                }
+            }
+
+            written[destination] = len(files)
+            files = append(files, PackageFile{
+                path: destination,
+                mode: category_permissions(category),
+                size: information.Size(),
+                config: category == "config",
+                category: category,
+                open: func() (io.ReadCloser, error) {
+                    return installer.payload.Open(name)
+                },
+            })
+        }
+    }
+
+    for _, remote := range installer.manifest.Remote {
+        fmt.Fprintf(os.Stderr, "Remote file not packaged: %s/%s\n", remote.Category, remote.Name)
+    }
+
+    programs := filepath.ToSlash(layout.Bin)
+    if programs == linux_binaries_directory {
+        return files
+    }
+
+    if installer.manifest.PathMode == "profile" {
+        content := installer.profile_script(programs)
+        return append(files, PackageFile{
+            path: path.Join("/etc/profile.d", installer.name + ".sh"),
+            mode: 0644,
+            size: int64(len(content)),
+            open: func() (io.ReadCloser, error) {
+                return io.NopCloser(strings.NewReader(content)), nil
+            },
+        })
+    }
+
+    for _, command := range installer.manifest.Commands {
+        files = append(files, PackageFile{
+            path: path.Join(linux_binaries_directory, command),
+            mode: 0777,
+            link: path.Join(programs, command),
+        })
+    }
+    return files
+}
+
+/*
+    This method moves data files of the package to the
+    default data directory (/usr/share/<package>/default-data),
+    package managers would replace user data on upgrade and
+    remove it with the package. It returns the post-install
+    commands copying missing data files.
+*/
+func (installer *Installer) stage_data_files(files []PackageFile) ([]PackageFile, string) {
+    directory := path.Join("/usr/share", installer.package_name(), "default-data")
+    seen := make(map[string]bool)
+
+    var staged []PackageFile
+    var script strings.Builder
+    for _, file := range files {
+        if file.category != "data" || file.directory || file.link != "" {
+            staged = append(staged, file)
+            continue
+        }
+
+        destination := path.Join(directory, file.path)
+        var parents []PackageFile
+        for parent := path.Dir(destination); parent != "/usr/share" && !seen[parent]; parent = path.Dir(parent) {
+            seen[parent] = true
+            parents = append([]PackageFile{{path: parent, mode: 0755, directory: true}}, parents...)
+        }
+        staged = append(staged, parents...)
+
+        script.WriteString("[ -e " + shell_quote(file.path) + " ] || cp -p " + shell_quote(destination) + " " + shell_quote(file.path) + "\n")
+        file.path = destination
+        staged = append(staged, file)
+    }
+    return staged, script.String()
+}
+
+/*
+    This function returns parents directories of a path
+    missing in the package, from the root directory.
+*/
+func missing_parents(file_path string, seen map[string]bool) []string {
+    var parents []string
+    for parent := path.Dir(file_path); parent != "/" && parent != "." && !seen[parent]; parent = path.Dir(parent) {
+        seen[parent] = true
+        parents = append([]string{parent}, parents...)
+    }
+    return parents
+}
+
+/*
+    This function checks if a file name is a systemd unit.
+*/
+func systemd_unit(name string) bool {
+    return strings.HasSuffix(name, ".service") || strings.HasSuffix(name, ".timer") || strings.HasSuffix(name, ".socket")
+}
+
+/*
+    This function returns names of packaged systemd units.
+*/
+func package_units(files []PackageFile) []string {
+    var units []string
+    for _, file := range files {
+        if file.category == "service" && systemd_unit(path.Base(file.path)) {
+            units = append(units, path.Base(file.path))
+        }
+    }
+    return units
+}
+
+/*
+    This function quotes a value for a shell script.
+*/
+func shell_quote(value string) string {
+    return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+/*
+    This method returns the post-install script body:
+    variables, Linux commands and systemd units enabled
+    with the services start policy.
+*/
+func (installer *Installer) post_install_script(units []string) string {
+    var names []string
+    for name := range installer.variables {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var script strings.Builder
+    for _, name := range names {
+        script.WriteString(name + "=" + shell_quote(installer.variables[name]) + "; export " + name + "\n")
+    }
+
+    for _, command := range installer.install_commands("linux") {
+        script.WriteString(command + "\n")
+    }
+
+    if len(units) > 0 {
+        script.WriteString("systemctl daemon-reload || true\n")
+        switch installer.options.ServiceStart {
+        case "start":
+            script.WriteString("systemctl enable --now " + strings.Join(units, " ") + " || true\n")
+        case "enable":
+            script.WriteString("systemctl enable " + strings.Join(units, " ") + " || true\n")
+        }
+    }
+    return script.String()
+}
+
+/*
+    This function returns the pre-remove script body
+    stopping and disabling systemd units.
+*/
+func pre_remove_script(units []string) string {
+    if len(units) == 0 {
+        return ""
+    }
+    return "systemctl disable --now " + strings.Join(units, " ") + " || true\n"
+}
+
+/*
+    This method returns the package name, the
+    application name in lower case with only
+    letters, digits and "+-." characters.
+*/
+func (installer *Installer) package_name() string {
+    return strings.Map(func(character rune) rune {
+        if (character >= 'a' && character <= 'z') || (character >= '0' && character <= '9') || strings.ContainsRune("+-.", character) {
+            return character
+        }
+        return '-'
+    }, strings.ToLower(installer.name))
+}
+
+/*
+    This method returns the package version
+    (manifest "version", default is 1.0.0).
+*/
+func (installer *Installer) package_version() string {
+    if installer.manifest.Version == "" {
+        return "1.0.0"
+    }
+    return installer.manifest.Version
+}
+
+/*
+    This method returns the package architecture: the
+    manifest "architecture" (a Go architecture or "all",
+    default is the build host architecture) with its name
+    in the package format.
+*/
+func (installer *Installer) package_architecture(names map[string]string) string {
+    architecture := installer.manifest.Architecture
+    if architecture == "" {
+        architecture = runtime.GOARCH
+    }
+
+    if name, ok := names[architecture]; ok {
+        return name
+    }
+    return architecture
+}
+
+/*
+    This method returns the package license name: the
+    manifest "license_name", or the manifest "license"
+    when it's a short name (like "MIT"), default is
+    "Proprietary".
+*/
+func (installer *Installer) package_license() string {
+    if installer.manifest.LicenseName != "" {
+        return installer.manifest.LicenseName
+    }
+
+    license := strings.TrimSpace(installer.manifest.License)
+    if license != "" && len(license) <= 64 && !strings.Contains(license, "\n") {
+        return license
+    }
+    return "Proprietary"
+}
+
+/*
+    This method returns the package description.
+*/
+func (installer *Installer) package_description() string {
+    if installer.manifest.Description == "" {
+        return installer.name + " installed by GoInstaller"
+    }
+    return installer.manifest.Description
+}
+
+/*
+    This function returns the packages modification time,
+    SOURCE_DATE_EPOCH is used for reproducible builds.
+*/
+func package_time() time.Time {
+    var seconds int64
+    if _, err := fmt.Sscan(os.Getenv("SOURCE_DATE_EPOCH"), &seconds); err == nil {
+        return time.Unix(seconds, 0).UTC()
+    }
+    return time.Now().UTC()
+}
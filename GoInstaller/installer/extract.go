@@ -0,0 +1,207 @@
+/*
+    This file implements the portable extraction for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// installer extract --to directory
+// installer export tar application.tar.gz
+
+package installer
+
+import (
+    "compress/gzip"
+    "path/filepath"
+    "archive/tar"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "os"
+)
+
+/*
+    Directories of payload categories in a portable tree.
+*/
+var portable_directories = map[string]string{
+    "data": "data",
+    "program": "bin",
+    "gui": "gui",
+    "service": "service",
+    "config": "config",
+}
+
+/*
+    This method writes selected payload files in a relocatable
+    directory tree (bin, data, service, gui and config), remote
+    files are downloaded. Root is not required, system paths
+    are not used and commands are not run.
+*/
+func (installer *Installer) Extract(ctx context.Context, directory string) error {
+    if directory == "" {
+        return failure(ExitUsage, "no extraction directory")
+    }
+
+    err := installer.prepare(Receipt{})
+    if err != nil {
+        return err
+    }
+
+    for _, category := range categories {
+        if !installer.category_selected(category) {
+            continue
+        }
+
+        destination := filepath.Join(directory, portable_directories[category])
+        err := installer.target.MkdirAll(destination, os.ModePerm)
+        if err != nil {
+            return failure(ExitDirectory, "creating directory %s: %v", destination, err)
+        }
+
+        for _, file := range installer.process_directory(File{path: destination, filetype: category}) {
+            if !installer.component_selected(installer.file_component(category + "/" + file.name)) {
+                continue
+            }
+            if ctx.Err() != nil {
+                return ctx.Err()
+            }
+
+            path := filepath.Join(destination, file.name)
+            hash, err := installer.copy_file(file, path)
+            if err == nil && installer.payload_hashes != nil && installer.payload_hashes[category + "/" + file.name] != hash {
+                err = errors.New("content doesn't match the signed manifest")
+            }
+            if err != nil {
+                return failure(ExitWrite, "writing file %s: %v", path, err)
+            }
+            fmt.Printf("Extracted: %s\n", path)
+        }
+
+        for _, remote := range installer.manifest.Remote {
+            if remote.Category != category || !installer.component_selected(installer.file_component(category + "/" + remote.Name)) {
+                continue
+            }
+
+            path := filepath.Join(destination, remote.Name)
+            _, err := installer.download_file(ctx, remote, path, category_permissions(category))
+            if err != nil {
+                return failure(ExitWrite, "writing file %s: %v", path, err)
+            }
+            fmt.Printf("Extracted: %s\n", path)
+        }
+    }
+    return nil
+}
+
+/*
+    This method returns selected payload files with
+    their path in the portable tree.
+*/
+func (installer *Installer) portable_files() []PackageFile {
+    var files []PackageFile
+    for _, category := range categories {
+        if !installer.category_selected(category) {
+            continue
+        }
+
+        directory := portable_directories[category]
+        files = append(files, PackageFile{path: directory, mode: 0755, directory: true})
+
+        entries, err := installer.payload_entries(category)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error reading embedded files (%s): %v\n", category, err)
+            continue
+        }
+
+        for _, entry := range entries {
+            name := category + "/" + entry.Name()
+            if entry.IsDir() || !installer.component_selected(installer.file_component(name)) {
+                continue
+            }
+
+            information, err := entry.Info()
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error reading embedded file %s: %v\n", name, err)
+                continue
+            }
+
+            files = append(files, PackageFile{
+                path: directory + "/" + entry.Name(),
+                mode: category_permissions(category),
+                size: information.Size(),
+                category: category,
+                open: func() (io.ReadCloser, error) {
+                    return installer.payload.Open(name)
+                },
+            })
+        }
+    }
+
+    for _, remote := range installer.manifest.Remote {
+        fmt.Fprintf(os.Stderr, "Remote file not packaged: %s/%s\n", remote.Category, remote.Name)
+    }
+    return files
+}
+
+/*
+    This method writes the portable tree in a .tar.gz
+    archive, in a "<name>-<version>" directory.
+*/
+func (installer *Installer) write_tarball(output string) error {
+    root := installer.package_name() + "-" + installer.package_version() + "/"
+    modified := package_time()
+
+    file, err := os.Create(output)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    compressor := gzip.NewWriter(file)
+    archive := tar.NewWriter(compressor)
+
+    files := append([]PackageFile{{path: "", mode: 0755, directory: true}}, installer.portable_files()...)
+    for _, entry := range files {
+        header := &tar.Header{
+            Typeflag: tar.TypeReg,
+            Name: root + entry.path,
+            Mode: int64(entry.mode.Perm()),
+            Size: entry.size,
+            ModTime: modified,
+        }
+
+        if entry.directory {
+            header.Typeflag = tar.TypeDir
+            header.Name = filepath.ToSlash(filepath.Clean(header.Name)) + "/"
+        }
+
+        err = archive.WriteHeader(header)
+        if err == nil && !entry.directory {
+            _, err = copy_package_file(archive, entry)
+        }
+        if err != nil {
+            return err
+        }
+    }
+
+    err = archive.Close()
+    if err == nil {
+        err = compressor.Close()
+    }
+    if err != nil {
+        return err
+    }
+    return file.Close()
+}
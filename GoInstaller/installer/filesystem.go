@@ -0,0 +1,597 @@
+/*
+    This file implements install target filesystems for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "strings"
+    "io/fs"
+    "bytes"
+    "sync"
+    "sort"
+    "time"
+    "io"
+    "os"
+)
+
+/*
+    The filesystem where software files are installed,
+    paths are absolute paths of the operating system.
+*/
+type FileSystem interface {
+    Stat(path string) (fs.FileInfo, error)
+    Lstat(path string) (fs.FileInfo, error)
+    Open(path string) (io.ReadCloser, error)
+    OpenFile(path string, flag int, permissions fs.FileMode) (WritableFile, error)
+    MkdirAll(path string, permissions fs.FileMode) error
+    Remove(path string) error
+    Rename(old_path string, new_path string) error
+    Chmod(path string, permissions fs.FileMode) error
+    Symlink(target string, link string) error
+    Readlink(link string) (string, error)
+}
+
+/*
+    A file opened for writing, downloads are resumed
+    with Seek and Truncate.
+*/
+type WritableFile interface {
+    io.Writer
+    io.Seeker
+    io.Closer
+    Truncate(size int64) error
+}
+
+/*
+    The operating system filesystem (default).
+*/
+type OSFileSystem struct{}
+
+/*
+    This method returns file information, links are followed.
+*/
+func (OSFileSystem) Stat(path string) (fs.FileInfo, error) {
+    return os.Stat(path)
+}
+
+/*
+    This method returns file information, links are not followed.
+*/
+func (OSFileSystem) Lstat(path string) (fs.FileInfo, error) {
+    return os.Lstat(path)
+}
+
+/*
+    This method opens a file to read it.
+*/
+func (OSFileSystem) Open(path string) (io.ReadCloser, error) {
+    return os.Open(path)
+}
+
+/*
+    This method opens a file to write it (os.OpenFile flags).
+*/
+func (OSFileSystem) OpenFile(path string, flag int, permissions fs.FileMode) (WritableFile, error) {
+    return os.OpenFile(path, flag, permissions)
+}
+
+/*
+    This method creates a directory and its missing parents.
+*/
+func (OSFileSystem) MkdirAll(path string, permissions fs.FileMode) error {
+    return os.MkdirAll(path, permissions)
+}
+
+/*
+    This method removes a file or an empty directory.
+*/
+func (OSFileSystem) Remove(path string) error {
+    return os.Remove(path)
+}
+
+/*
+    This method moves a file.
+*/
+func (OSFileSystem) Rename(old_path string, new_path string) error {
+    return os.Rename(old_path, new_path)
+}
+
+/*
+    This method changes file permissions.
+*/
+func (OSFileSystem) Chmod(path string, permissions fs.FileMode) error {
+    return os.Chmod(path, permissions)
+}
+
+/*
+    This method creates a symbolic link.
+*/
+func (OSFileSystem) Symlink(target string, link string) error {
+    return os.Symlink(target, link)
+}
+
+/*
+    This method returns the target of a symbolic link.
+*/
+func (OSFileSystem) Readlink(link string) (string, error) {
+    return os.Readlink(link)
+}
+
+/*
+    This function reads a file of a filesystem.
+*/
+func read_target(files FileSystem, path string) ([]byte, error) {
+    file, err := files.Open(path)
+    if err != nil {
+        return nil, err
+    }
+    defer file.Close()
+    return io.ReadAll(file)
+}
+
+/*
+    This function writes a file of a filesystem.
+*/
+func write_target(files FileSystem, path string, content []byte, permissions fs.FileMode) error {
+    file, err := files.OpenFile(path, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, permissions)
+    if err != nil {
+        return err
+    }
+
+    _, err = file.Write(content)
+    close_err := file.Close()
+    if err != nil {
+        return err
+    }
+    return close_err
+}
+
+/*
+    An in-memory filesystem to run the installer without
+    touching the system, it's safe for concurrent use. The
+    zero value is an empty filesystem.
+*/
+type MemoryFileSystem struct {
+    lock sync.Mutex
+    entries map[string]*memory_entry
+}
+
+type memory_entry struct {
+    data []byte
+    mode fs.FileMode
+    target string
+    modified time.Time
+}
+
+type memory_information struct {
+    name string
+    entry memory_entry
+}
+
+type memory_file struct {
+    files *MemoryFileSystem
+    entry *memory_entry
+    offset int64
+    append bool
+}
+
+/*
+    This function returns an empty in-memory filesystem.
+*/
+func NewMemoryFileSystem() *MemoryFileSystem {
+    return &MemoryFileSystem{entries: make(map[string]*memory_entry)}
+}
+
+/*
+    This method returns paths of files, directories and
+    links of the in-memory filesystem, sorted.
+*/
+func (files *MemoryFileSystem) Paths() []string {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    var paths []string
+    for path := range files.entries {
+        paths = append(paths, path)
+    }
+    sort.Strings(paths)
+    return paths
+}
+
+/*
+    This method returns the content of a file.
+*/
+func (files *MemoryFileSystem) ReadFile(path string) ([]byte, error) {
+    return read_target(files, path)
+}
+
+/*
+    This method returns the entry of a path, links are
+    followed when follow is true, the lock is held.
+*/
+func (files *MemoryFileSystem) lookup(operation string, path string, follow bool) (string, *memory_entry, error) {
+    path = filepath.Clean(path)
+    for depth := 0; depth < 40; depth++ {
+        entry, ok := files.entries[path]
+        if !ok && path == filepath.Dir(path) {
+            return path, &memory_entry{mode: fs.ModeDir | 0755}, nil
+        }
+        if !ok {
+            return path, nil, &fs.PathError{Op: operation, Path: path, Err: fs.ErrNotExist}
+        }
+        if !follow || entry.mode & fs.ModeSymlink == 0 {
+            return path, entry, nil
+        }
+
+        target := entry.target
+        if !filepath.IsAbs(target) {
+            target = filepath.Join(filepath.Dir(path), target)
+        }
+        path = filepath.Clean(target)
+    }
+    return path, nil, &fs.PathError{Op: operation, Path: path, Err: fs.ErrInvalid}
+}
+
+/*
+    This method saves the entry of a path, the lock is held.
+*/
+func (files *MemoryFileSystem) set(path string, entry *memory_entry) {
+    if files.entries == nil {
+        files.entries = make(map[string]*memory_entry)
+    }
+    files.entries[path] = entry
+}
+
+/*
+    This method checks that the parent of a path is a
+    directory, the lock is held.
+*/
+func (files *MemoryFileSystem) check_parent(operation string, path string) error {
+    _, parent, err := files.lookup(operation, filepath.Dir(path), true)
+    if err != nil {
+        return &fs.PathError{Op: operation, Path: path, Err: fs.ErrNotExist}
+    }
+    if !parent.mode.IsDir() {
+        return &fs.PathError{Op: operation, Path: path, Err: fs.ErrInvalid}
+    }
+    return nil
+}
+
+/*
+    This method returns file information, links are followed.
+*/
+func (files *MemoryFileSystem) Stat(path string) (fs.FileInfo, error) {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    resolved, entry, err := files.lookup("stat", path, true)
+    if err != nil {
+        return nil, err
+    }
+    return memory_information{name: filepath.Base(resolved), entry: *entry}, nil
+}
+
+/*
+    This method returns file information, links are not followed.
+*/
+func (files *MemoryFileSystem) Lstat(path string) (fs.FileInfo, error) {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    resolved, entry, err := files.lookup("lstat", path, false)
+    if err != nil {
+        return nil, err
+    }
+    return memory_information{name: filepath.Base(resolved), entry: *entry}, nil
+}
+
+/*
+    This method opens a file to read it.
+*/
+func (files *MemoryFileSystem) Open(path string) (io.ReadCloser, error) {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    _, entry, err := files.lookup("open", path, true)
+    if err != nil {
+        return nil, err
+    }
+    if entry.mode.IsDir() {
+        return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrInvalid}
+    }
+    return io.NopCloser(bytes.NewReader(bytes.Clone(entry.data))), nil
+}
+
+/*
+    This method opens a file to write it (os.OpenFile flags).
+*/
+func (files *MemoryFileSystem) OpenFile(path string, flag int, permissions fs.FileMode) (WritableFile, error) {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    resolved, entry, err := files.lookup("open", path, true)
+    if err == nil && flag & os.O_CREATE != 0 && flag & os.O_EXCL != 0 {
+        return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrExist}
+    }
+    if err != nil && flag & os.O_CREATE == 0 {
+        return nil, err
+    }
+
+    if entry == nil {
+        err = files.check_parent("open", resolved)
+        if err != nil {
+            return nil, err
+        }
+        entry = &memory_entry{mode: permissions.Perm(), modified: time.Now()}
+        files.set(resolved, entry)
+    } else if entry.mode.IsDir() {
+        return nil, &fs.PathError{Op: "open", Path: path, Err: fs.ErrInvalid}
+    }
+
+    if flag & os.O_TRUNC != 0 {
+        entry.data = nil
+        entry.modified = time.Now()
+    }
+    return &memory_file{files: files, entry: entry, append: flag & os.O_APPEND != 0}, nil
+}
+
+/*
+    This method creates a directory and its missing parents.
+*/
+func (files *MemoryFileSystem) MkdirAll(path string, permissions fs.FileMode) error {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    path = filepath.Clean(path)
+    var missing []string
+    for parent := path; parent != filepath.Dir(parent); parent = filepath.Dir(parent) {
+        _, entry, err := files.lookup("mkdir", parent, true)
+        if err == nil && !entry.mode.IsDir() {
+            return &fs.PathError{Op: "mkdir", Path: parent, Err: fs.ErrExist}
+        }
+        if err == nil {
+            break
+        }
+        missing = append(missing, parent)
+    }
+
+    for _, directory := range missing {
+        files.set(directory, &memory_entry{mode: fs.ModeDir | permissions.Perm(), modified: time.Now()})
+    }
+    return nil
+}
+
+/*
+    This method removes a file or an empty directory.
+*/
+func (files *MemoryFileSystem) Remove(path string) error {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    path = filepath.Clean(path)
+    entry, ok := files.entries[path]
+    if !ok {
+        return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrNotExist}
+    }
+
+    if entry.mode.IsDir() {
+        prefix := path + string(filepath.Separator)
+        for other := range files.entries {
+            if strings.HasPrefix(other, prefix) {
+                return &fs.PathError{Op: "remove", Path: path, Err: fs.ErrExist}
+            }
+        }
+    }
+
+    delete(files.entries, path)
+    return nil
+}
+
+/*
+    This method moves a file.
+*/
+func (files *MemoryFileSystem) Rename(old_path string, new_path string) error {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    old_path = filepath.Clean(old_path)
+    new_path = filepath.Clean(new_path)
+    entry, ok := files.entries[old_path]
+    if !ok {
+        return &fs.PathError{Op: "rename", Path: old_path, Err: fs.ErrNotExist}
+    }
+
+    err := files.check_parent("rename", new_path)
+    if err != nil {
+        return err
+    }
+
+    prefix := old_path + string(filepath.Separator)
+    for other, child := range files.entries {
+        if strings.HasPrefix(other, prefix) {
+            delete(files.entries, other)
+            files.entries[new_path + other[len(old_path):]] = child
+        }
+    }
+
+    delete(files.entries, old_path)
+    files.set(new_path, entry)
+    return nil
+}
+
+/*
+    This method changes file permissions.
+*/
+func (files *MemoryFileSystem) Chmod(path string, permissions fs.FileMode) error {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    _, entry, err := files.lookup("chmod", path, true)
+    if err != nil {
+        return err
+    }
+    entry.mode = entry.mode.Type() | permissions.Perm()
+    return nil
+}
+
+/*
+    This method creates a symbolic link.
+*/
+func (files *MemoryFileSystem) Symlink(target string, link string) error {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    link = filepath.Clean(link)
+    if _, ok := files.entries[link]; ok {
+        return &os.LinkError{Op: "symlink", Old: target, New: link, Err: fs.ErrExist}
+    }
+
+    err := files.check_parent("symlink", link)
+    if err != nil {
+        return err
+    }
+    files.set(link, &memory_entry{mode: fs.ModeSymlink | 0777, target: target, modified: time.Now()})
+    return nil
+}
+
+/*
+    This method returns the target of a symbolic link.
+*/
+func (files *MemoryFileSystem) Readlink(link string) (string, error) {
+    files.lock.Lock()
+    defer files.lock.Unlock()
+
+    _, entry, err := files.lookup("readlink", link, false)
+    if err != nil {
+        return "", err
+    }
+    if entry.mode & fs.ModeSymlink == 0 {
+        return "", &fs.PathError{Op: "readlink", Path: link, Err: fs.ErrInvalid}
+    }
+    return entry.target, nil
+}
+
+/*
+    This method writes data at the file offset.
+*/
+func (file *memory_file) Write(data []byte) (int, error) {
+    file.files.lock.Lock()
+    defer file.files.lock.Unlock()
+
+    if file.append {
+        file.offset = int64(len(file.entry.data))
+    }
+
+    end := file.offset + int64(len(data))
+    if end > int64(len(file.entry.data)) {
+        file.entry.data = append(file.entry.data, make([]byte, end - int64(len(file.entry.data)))...)
+    }
+    copy(file.entry.data[file.offset:], data)
+    file.offset = end
+    file.entry.modified = time.Now()
+    return len(data), nil
+}
+
+/*
+    This method sets the file offset.
+*/
+func (file *memory_file) Seek(offset int64, whence int) (int64, error) {
+    file.files.lock.Lock()
+    defer file.files.lock.Unlock()
+
+    switch whence {
+    case io.SeekCurrent:
+        offset += file.offset
+    case io.SeekEnd:
+        offset += int64(len(file.entry.data))
+    }
+
+    if offset < 0 {
+        return file.offset, fs.ErrInvalid
+    }
+    file.offset = offset
+    return offset, nil
+}
+
+/*
+    This method changes the file size.
+*/
+func (file *memory_file) Truncate(size int64) error {
+    file.files.lock.Lock()
+    defer file.files.lock.Unlock()
+
+    if size < 0 {
+        return fs.ErrInvalid
+    }
+    if size <= int64(len(file.entry.data)) {
+        file.entry.data = file.entry.data[:size]
+    } else {
+        file.entry.data = append(file.entry.data, make([]byte, size - int64(len(file.entry.data)))...)
+    }
+    return nil
+}
+
+/*
+    This method closes the file.
+*/
+func (file *memory_file) Close() error {
+    return nil
+}
+
+/*
+    This method returns the file name.
+*/
+func (information memory_information) Name() string {
+    return information.name
+}
+
+/*
+    This method returns the file size.
+*/
+func (information memory_information) Size() int64 {
+    return int64(len(information.entry.data))
+}
+
+/*
+    This method returns the file mode.
+*/
+func (information memory_information) Mode() fs.FileMode {
+    return information.entry.mode
+}
+
+/*
+    This method returns the modification time.
+*/
+func (information memory_information) ModTime() time.Time {
+    return information.entry.modified
+}
+
+/*
+    This method checks if the file is a directory.
+*/
+func (information memory_information) IsDir() bool {
+    return information.entry.mode.IsDir()
+}
+
+/*
+    This method returns nil, there is no system data.
+*/
+func (information memory_information) Sys() any {
+    return nil
+}
@@ -0,0 +1,1213 @@
+/*
+    This file implements an installer for Linux and Windows softwares
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+/*
+    Package installer installs a payload (data, program, gui,
+    service and config directories) described by a manifest.
+
+    A custom installer embeds its own payload and calls the
+    library from a small main:
+
+        setup, err := installer.New(installer.Options{Name: "application", Payload: payload})
+        if err == nil {
+            err = setup.Install(context.Background())
+        }
+*/
+package installer
+
+import (
+    "encoding/json"
+    "encoding/hex"
+    "crypto/sha256"
+    "path/filepath"
+    "runtime"
+    "context"
+    "strings"
+    "errors"
+    "bufio"
+    "sync"
+    "io/fs"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+const copy_buffer_size = 256 * 1024
+
+/*
+    Exit codes of installer errors.
+*/
+const (
+    ExitUsage = 1
+    ExitDirectory = 1
+    ExitWrite = 2
+    ExitPayload = 3
+    ExitReceipt = 4
+    ExitPrivileges = 5
+    ExitSignature = 6
+    ExitLicense = 7
+    ExitLock = 8
+    ExitService = 9
+    ExitHealthCheck = 10
+)
+
+/*
+    Cancelled is returned when the user cancels
+    the installation in the wizard.
+*/
+var Cancelled = errors.New("installation cancelled")
+
+type Error struct {
+    Code int
+    Err error
+}
+
+/*
+    This method returns the error message.
+*/
+func (err *Error) Error() string {
+    return err.Err.Error()
+}
+
+/*
+    This method returns the wrapped error.
+*/
+func (err *Error) Unwrap() error {
+    return err.Err
+}
+
+/*
+    This function returns an installer error with an exit code.
+*/
+func failure(code int, format string, arguments ...any) error {
+    return &Error{Code: code, Err: fmt.Errorf(format, arguments...)}
+}
+
+type Options struct {
+    Name string
+    Payload fs.FS
+    Manifest []byte
+    Signature fs.FS
+    PublicKey string
+    LinuxCommands []string
+    WindowsCommands []string
+    Yes bool
+    AcceptLicense bool
+    With []string
+    Without []string
+    Answers string
+    RecordAnswers string
+    ServiceStart string
+    Location string
+    Progress bool
+    FileSystem FileSystem
+    LockTimeout time.Duration
+    LockFile string
+    ServiceManager ServiceManager
+    ServiceStopTimeout time.Duration
+    CommandRunner CommandRunner
+    Adopt bool
+}
+
+type Installer struct {
+    options Options
+    name string
+    payload fs.FS
+    manifest_data []byte
+    manifest Manifest
+    receipt Receipt
+    previous_receipt Receipt
+    payload_hashes map[string]string
+    variables map[string]string
+    selected_components map[string]bool
+    selected_categories map[string]bool
+    license_acceptance *LicenseAcceptance
+    input *bufio.Reader
+    steps []Step
+    target FileSystem
+    services ServiceManager
+    commands CommandRunner
+    stopped_services []string
+    started_services []string
+    checks_error error
+    backups []string
+    backups_lock sync.Mutex
+    adopted []string
+}
+
+type File struct {
+    filetype string
+    path string
+    name string
+    open func() (io.ReadCloser, error)
+    remote *RemoteFile
+    component string
+    callback func(string)
+}
+
+type InstallResult struct {
+    path string
+    messages []string
+    entry *ReceiptFile
+    err error
+}
+
+type Manifest struct {
+    Name string `json:"name"`
+    License string `json:"license"`
+    LicenseName string `json:"license_name"`
+    LicenseFile string `json:"license_file"`
+    LinuxCommands []string `json:"linux_commands"`
+    WindowsCommands []string `json:"windows_commands"`
+    Commands []string `json:"commands"`
+    PathMode string `json:"path_mode"`
+    Layout string `json:"layout"`
+    Paths Layout `json:"paths"`
+    Categories map[string]string `json:"categories"`
+    LogRotate *LogRotate `json:"logrotate"`
+    Workers int `json:"workers"`
+    Remote []RemoteFile `json:"remote"`
+    Components []Component `json:"components"`
+    Variables []Variable `json:"variables"`
+    Version string `json:"version"`
+    Description string `json:"description"`
+    Maintainer string `json:"maintainer"`
+    Architecture string `json:"architecture"`
+    Entrypoint []string `json:"entrypoint"`
+    Checks []HealthCheck `json:"checks"`
+}
+
+type LogRotate struct {
+    Pattern string `json:"pattern"`
+    Frequency string `json:"frequency"`
+    Rotate int `json:"rotate"`
+    Compress bool `json:"compress"`
+    MaxSize string `json:"max_size"`
+    CopyTruncate bool `json:"copytruncate"`
+    PostRotate string `json:"postrotate"`
+}
+
+type Receipt struct {
+    Application string `json:"application"`
+    InstalledAt time.Time `json:"installed_at"`
+    Location string `json:"location,omitempty"`
+    License *LicenseAcceptance `json:"license,omitempty"`
+    Components []string `json:"components"`
+    ManifestComponents []string `json:"manifest_components,omitempty"`
+    Variables map[string]string `json:"variables,omitempty"`
+    ServiceStart string `json:"service_start,omitempty"`
+    Files []ReceiptFile `json:"files"`
+}
+
+type ReceiptFile struct {
+    Path string `json:"path"`
+    Category string `json:"category"`
+    Target string `json:"target,omitempty"`
+    Hash string `json:"sha256,omitempty"`
+    Component string `json:"component,omitempty"`
+}
+
+type RegistryKey struct {
+    value_name string
+    value_data any
+}
+
+/*
+    This function returns an installer for a payload, the
+    payload "manifest.json" replaces Options.Manifest. The
+    payload is checked when a signature public key is pinned.
+*/
+func New(options Options) (*Installer, error) {
+    if options.Payload == nil {
+        return nil, failure(ExitPayload, "no payload")
+    }
+
+    installer := &Installer{
+        options: options,
+        name: options.Name,
+        payload: options.Payload,
+        variables: make(map[string]string),
+        selected_components: make(map[string]bool),
+        input: bufio.NewReader(os.Stdin),
+        target: options.FileSystem,
+    }
+    if installer.target == nil {
+        installer.target = OSFileSystem{}
+    }
+
+    installer.services = options.ServiceManager
+    if !installer.system_target() && installer.services == nil {
+        installer.services = NoServiceManager{}
+    } else if installer.services == nil {
+        installer.services = default_service_manager()
+    }
+
+    installer.commands = options.CommandRunner
+    if !installer.system_target() && installer.commands == nil {
+        installer.commands = NoCommandRunner{}
+    } else if installer.commands == nil {
+        installer.commands = system_command_runner{}
+    }
+    installer.steps = builtin_steps()
+    installer.manifest_data = payload_file(options.Payload, "manifest.json", options.Manifest)
+
+    err := installer.verify_payload()
+    if err != nil {
+        return nil, err
+    }
+
+    err = installer.load_manifest()
+    if err != nil {
+        return nil, err
+    }
+    return installer, nil
+}
+
+/*
+    This method installs the software with install steps:
+
+    1. Check privileges
+    2. Select components and ask install choices
+    3. Create directories
+    4. Install/Write files
+    5. Configure log rotation
+    6. Add programs to the PATH
+    7. Run commands
+    8. Enable/start services
+    9. Run health checks
+    10. Save the install receipt
+
+    On a terminal, without Options.Yes, a wizard asks install
+    choices. The license must be accepted before the install.
+    An answer file (Options.Answers) replaces the wizard.
+    Custom steps are added with AddStepBefore and AddStepAfter,
+    when a step fails applied steps are rolled back. Failed
+    health checks fail the install (ExitHealthCheck) but the
+    install is kept, except for checks with rollback. Installs
+    and uninstalls of the application are exclusive.
+*/
+func (installer *Installer) Install(ctx context.Context) error {
+    err := installer.require_privileges()
+    if err != nil {
+        return err
+    }
+
+    lock, err := installer.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer lock.release()
+
+    previous, _ := installer.load_receipt(receipt_path(installer.get_layout().Data))
+    err = installer.prepare(previous)
+    if err == nil {
+        err = installer.apply_steps(ctx)
+    }
+    if err != nil {
+        return err
+    }
+    return installer.checks_error
+}
+
+/*
+    This method runs the wizard, checks the license and
+    the services policy and starts the install receipt.
+*/
+func (installer *Installer) apply_choices() error {
+    if !installer.options.Yes && is_terminal() {
+        accepted, err := installer.run_wizard()
+        if err != nil {
+            return err
+        }
+        if !accepted {
+            return Cancelled
+        }
+    }
+
+    err := installer.require_license()
+    if err == nil {
+        err = installer.check_service_start()
+    }
+    if err == nil && installer.options.RecordAnswers != "" {
+        err = installer.record_answers(installer.options.RecordAnswers)
+    }
+    if err != nil {
+        return err
+    }
+
+    installer.receipt = Receipt{
+        Application: installer.name,
+        InstalledAt: time.Now().UTC(),
+        Location: installer.options.Location,
+        License: installer.license_acceptance,
+        Components: installer.installed_components(),
+        ManifestComponents: installer.manifest_components(),
+        Variables: installer.variables,
+        ServiceStart: installer.options.ServiceStart,
+    }
+    return nil
+}
+
+/*
+    This method prepares install choices from the previous
+    install, the manifest defaults and the answer file.
+*/
+func (installer *Installer) prepare(previous Receipt) error {
+    installer.previous_receipt = previous
+    installer.default_choices()
+
+    err := installer.select_components()
+    if err == nil && installer.options.Answers != "" {
+        err = installer.load_answers(installer.options.Answers)
+    }
+    return err
+}
+
+/*
+    This method returns an error when the process
+    doesn't have privileges to install the software.
+*/
+func (installer *Installer) require_privileges() error {
+    if _, ok := installer.target.(OSFileSystem); !ok {
+        return nil
+    }
+
+    privileges, err := check_privileges()
+    if err != nil {
+        return failure(ExitPrivileges, "this software installer requires privileges: %v", err)
+    }
+    if !privileges {
+        return failure(ExitPrivileges, "this software installer requires privileges")
+    }
+    return nil
+}
+
+/*
+    This method parses the manifest, the manifest
+    "name" replaces the application name. Without
+    manifest, defaults settings are used.
+*/
+func (installer *Installer) load_manifest() error {
+    if len(installer.manifest_data) == 0 {
+        return installer.check_layout()
+    }
+
+    err := json.Unmarshal(installer.manifest_data, &installer.manifest)
+    if err != nil {
+        return failure(ExitPayload, "parsing the embedded manifest: %v", err)
+    }
+
+    if installer.manifest.Name != "" {
+        installer.name = installer.manifest.Name
+    }
+
+    err = installer.validate_checks()
+    if err == nil {
+        err = installer.validate_remote()
+    }
+    if err == nil {
+        err = installer.validate_log_rotation()
+    }
+    if err == nil {
+        err = installer.validate_license()
+    }
+    if err != nil {
+        return err
+    }
+    return installer.check_layout()
+}
+
+/*
+    This function returns a file from the payload archive
+    when it's present, otherwise the embedded content.
+*/
+func payload_file(files fs.FS, name string, embedded []byte) []byte {
+    content, err := fs.ReadFile(files, name)
+    if err != nil {
+        return embedded
+    }
+    return content
+}
+
+/*
+    This method creates software directories.
+*/
+func (installer *Installer) create_directories() (Layout, error) {
+    layout := installer.get_layout()
+    for _, category := range categories {
+        err := installer.create_directory(installer.category_directory(layout, category))
+        if err != nil {
+            return layout, err
+        }
+    }
+
+    err := installer.create_directory(layout.Data)
+    if err != nil {
+        return layout, err
+    }
+
+    if runtime.GOOS == "windows" {
+        if installer.windows_system() {
+            add_application_source_log(installer.name)
+        }
+        // new_registry_key(`SYSTEM\CurrentControlSet\Services\EventLog\Application\` + application_name, []RegistryKey{{"CustomSource", 1}, {"EventMessageFile", `%SystemRoot%\System32\EventCreate.exe`}, {"TypesSupported", 7}})
+        return layout, nil
+    }
+
+    return layout, installer.create_directory(layout.Log)
+}
+
+/*
+    This method creates a directory, created
+    directories are saved in the install receipt.
+*/
+func (installer *Installer) create_directory(path string) error {
+    var missing []string
+    for parent := path; !installer.file_exists(parent); parent = filepath.Dir(parent) {
+        missing = append(missing, parent)
+        if parent == filepath.Dir(parent) {
+            break
+        }
+    }
+
+    err := installer.target.MkdirAll(path, os.ModePerm)
+    if err != nil {
+        return failure(ExitDirectory, "creating directory %s: %v", path, err)
+    }
+
+    for index := len(missing) - 1; index >= 0; index-- {
+        installer.receipt.Files = append(installer.receipt.Files, ReceiptFile{Path: missing[index], Category: "directory"})
+    }
+    return nil
+}
+
+/*
+    This method returns payload files to install with
+    their destination directory, remote files included.
+*/
+func (installer *Installer) payload_files(layout Layout) []File {
+    var files []File
+
+    file := File{}
+    file.path = installer.category_directory(layout, "data")
+    file.filetype = "data"
+    files = append(files, installer.process_directory(file)...)
+
+    file.path = installer.category_directory(layout, "program")
+    file.filetype = "program"
+    files = append(files, installer.process_directory(file)...)
+
+    file.path = installer.category_directory(layout, "gui")
+    file.filetype = "gui"
+    if installer.windows_system() {
+        file.callback = installer.add_to_windows_menu
+    }
+    files = append(files, installer.process_directory(file)...)
+
+    if installer.windows_system() {
+        file.callback = installer.create_service
+    }
+
+    file.path = installer.category_directory(layout, "service")
+    file.filetype = "service"
+    files = append(files, installer.process_directory(file)...)
+
+    file.callback = nil
+    file.path = installer.category_directory(layout, "config")
+    file.filetype = "config"
+    files = append(files, installer.process_directory(file)...)
+    files = append(files, installer.remote_files(layout)...)
+
+    var selected []File
+    for _, file := range files {
+        file.component = installer.file_component(file.filetype + "/" + file.name)
+        if installer.category_selected(file.filetype) && installer.component_selected(file.component) {
+            selected = append(selected, file)
+        }
+    }
+    return selected
+}
+
+/*
+    This method installs selected payload files and removes
+    files of components unselected since the previous install.
+*/
+func (installer *Installer) process_directories(ctx context.Context, layout Layout) error {
+    err := installer.install_files(ctx, installer.payload_files(layout))
+    if err != nil {
+        return err
+    }
+
+    installer.remove_unselected_components()
+    return nil
+}
+
+/*
+    This function returns entries of a payload category,
+    a missing category directory is an empty category.
+*/
+func category_entries(files fs.FS, category string) ([]fs.DirEntry, error) {
+    entries, err := fs.ReadDir(files, category)
+    if errors.Is(err, fs.ErrNotExist) {
+        return nil, nil
+    }
+    return entries, err
+}
+
+/*
+    This method returns entries of a payload category without
+    the manifest "license_file": the license is shown to be
+    accepted, it's not installed, packed or extracted.
+*/
+func (installer *Installer) payload_entries(category string) ([]fs.DirEntry, error) {
+    entries, err := category_entries(installer.payload, category)
+    var files []fs.DirEntry
+    for _, entry := range entries {
+        if category + "/" + entry.Name() != installer.manifest.LicenseFile {
+            files = append(files, entry)
+        }
+    }
+    return files, err
+}
+
+/*
+    This method reads directory from embeded files.
+*/
+func (installer *Installer) process_directory(file File) []File {
+    file_entries, err := installer.payload_entries(file.filetype)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading embedded files (%s): %v\n", file.filetype, err)
+        return nil
+    }
+
+    var entries []File
+    for _, entry := range file_entries {
+        entries = append(entries, process_file(installer.payload, entry, file))
+    }
+    return entries
+}
+
+
+/*
+    This function reads file from embeded files.
+*/
+func process_file(files fs.FS, entry fs.DirEntry, file File) File {
+    file.name = entry.Name()
+    file_path := file.filetype + "/" + file.name
+    file.open = func() (io.ReadCloser, error) {
+        return files.Open(file_path)
+    }
+    return file
+}
+
+/*
+    This method writes files with a bounded worker pool
+    (manifest "workers", default is the number of CPU).
+
+    Existing files in a shared directory (not owned by the
+    application) are never overwritten when they are not
+    installed by the previous install, nothing is written.
+    Files with the same destination are written in order by
+    the same worker. Outputs, receipt entries and callbacks
+    are processed in the payload order, the first error (or
+    the context cancellation) stops all workers.
+*/
+func (installer *Installer) install_files(ctx context.Context, files []File) error {
+    groups := make(map[string][]int)
+    var destinations []string
+    for index, file := range files {
+        destination := filepath.Join(file.path, file.name)
+        if _, ok := groups[destination]; !ok {
+            destinations = append(destinations, destination)
+        }
+        groups[destination] = append(groups[destination], index)
+    }
+
+    for _, destination := range destinations {
+        file := files[groups[destination][0]]
+        if file.filetype == "data" || !installer.foreign_file(file.path, destination) {
+            continue
+        }
+
+        if !installer.options.Adopt {
+            return failure(ExitWrite, "existing file not overwritten: %s (not installed by %s, adopt existing files to replace it)", destination, installer.name)
+        }
+        fmt.Printf("Existing file adopted: %s\n", destination)
+        installer.adopted = append(installer.adopted, destination)
+    }
+
+    install_context, cancel := context.WithCancel(ctx)
+    defer cancel()
+
+    results := make([]InstallResult, len(files))
+    jobs := make(chan []int)
+    done := make(chan int)
+    var first_error error
+    var once sync.Once
+    var workers sync.WaitGroup
+
+    for range installer.worker_count() {
+        workers.Add(1)
+        go func() {
+            defer workers.Done()
+            for group := range jobs {
+                for _, index := range group {
+                    if install_context.Err() != nil {
+                        results[index].err = install_context.Err()
+                    } else {
+                        results[index] = installer.write_file(install_context, files[index])
+                    }
+
+                    if results[index].err != nil {
+                        once.Do(func() {
+                            first_error = results[index].err
+                            cancel()
+                        })
+                    }
+                    done <- index
+                }
+            }
+        }()
+    }
+
+    go func() {
+        defer close(jobs)
+        for _, destination := range destinations {
+            select {
+            case jobs <- groups[destination]:
+            case <-install_context.Done():
+                return
+            }
+        }
+    }()
+
+    go func() {
+        workers.Wait()
+        close(done)
+    }()
+
+    completed := make([]bool, len(files))
+    next := 0
+    for index := range done {
+        completed[index] = true
+        for next < len(files) && completed[next] && results[next].err == nil {
+            prefix := ""
+            if installer.options.Progress {
+                prefix = fmt.Sprintf("[%d/%d] ", next + 1, len(files))
+            }
+            installer.flush_result(files[next], results[next], prefix)
+            next++
+        }
+    }
+
+    if first_error == nil && next < len(files) {
+        first_error = ctx.Err()
+    }
+    if first_error != nil {
+        installer.record_written(results[next:])
+        return failure(ExitWrite, "installing files: %v", first_error)
+    }
+    return nil
+}
+
+/*
+    This method checks if a destination is an existing file,
+    not installed by the previous install, in a shared
+    directory (like /usr/local/bin). Foreign files are
+    replaced only when they are adopted (Options.Adopt),
+    like files of an install without receipt.
+*/
+func (installer *Installer) foreign_file(directory string, destination string) bool {
+    if !installer.shared_directory(directory) {
+        return false
+    }
+
+    if _, err := installer.target.Lstat(destination); err != nil {
+        return false
+    }
+
+    for _, entry := range installer.previous_receipt.Files {
+        if entry.Path == destination {
+            return false
+        }
+    }
+    return true
+}
+
+/*
+    This method checks if a directory is shared with other
+    software: a system directory or a directory without the
+    application name (or the package name) as a path element.
+*/
+func (installer *Installer) shared_directory(directory string) bool {
+    directory = filepath.ToSlash(filepath.Clean(directory))
+    if contains(system_directories, directory) {
+        return true
+    }
+
+    for _, element := range strings.Split(directory, "/") {
+        if element == installer.name || element == installer.package_name() {
+            return false
+        }
+    }
+    return true
+}
+
+/*
+    This method returns the number of workers writing files.
+*/
+func (installer *Installer) worker_count() int {
+    if installer.manifest.Workers > 0 {
+        return installer.manifest.Workers
+    }
+    return runtime.NumCPU()
+}
+
+/*
+    This method prints the result of a written file, saves
+    it in the install receipt and calls the file callback.
+*/
+func (installer *Installer) flush_result(file File, result InstallResult, prefix string) {
+    for _, message := range result.messages {
+        fmt.Println(prefix + message)
+    }
+
+    if result.entry != nil {
+        installer.receipt.Files = append(installer.receipt.Files, *result.entry)
+    }
+
+    if file.callback != nil {
+        file.callback(result.path)
+    }
+}
+
+/*
+    This method saves in the install receipt files written
+    after a failed file, they are not printed but the
+    rollback must remove them.
+*/
+func (installer *Installer) record_written(results []InstallResult) {
+    for _, result := range results {
+        if result.err == nil && result.entry != nil {
+            installer.receipt.Files = append(installer.receipt.Files, *result.entry)
+        }
+    }
+}
+
+/*
+    This method checks if file exists.
+*/
+func (installer *Installer) file_exists(file_path string) bool {
+    _, err := installer.target.Stat(file_path)
+    return !errors.Is(err, os.ErrNotExist)
+}
+
+/*
+    This method writes the file content, it's safe
+    to call from multiple goroutines for different files.
+*/
+func (installer *Installer) write_file(ctx context.Context, file File) InstallResult {
+    var result InstallResult
+    fullfilepath := filepath.Join(file.path, file.name)
+    result.path = fullfilepath
+
+    if file.filetype != "data" || !installer.file_exists(fullfilepath) {
+        destination := fullfilepath
+        if file.filetype == "config" {
+            var message string
+            destination, message = installer.config_destination(fullfilepath, file)
+            if message != "" {
+                result.messages = append(result.messages, message)
+            }
+        }
+
+        var hash string
+        err := installer.backup_file(destination)
+        if err != nil {
+            result.err = fmt.Errorf("saving file %s: %v", destination, err)
+            return result
+        }
+
+        if file.remote != nil {
+            hash, err = installer.download_file(ctx, *file.remote, destination, category_permissions(file.filetype))
+        } else {
+            hash, err = installer.copy_file(file, destination)
+            if err == nil && installer.payload_hashes != nil && installer.payload_hashes[file.filetype + "/" + file.name] != hash {
+                err = errors.New("content doesn't match the signed manifest")
+            }
+        }
+
+        if err != nil {
+            result.err = fmt.Errorf("writing file %s: %v", destination, err)
+            return result
+        }
+
+        result.messages = append(result.messages, "Installed: " + destination)
+        result.entry = &ReceiptFile{Path: destination, Category: file.filetype, Hash: hash, Component: file.component}
+    } else {
+        result.messages = append(result.messages, "Data file already exists: " + fullfilepath)
+    }
+    return result
+}
+
+/*
+    This method streams the file content to the
+    destination with a bounded buffer and returns
+    the SHA256 of written data.
+*/
+func (installer *Installer) copy_file(file File, destination string) (string, error) {
+    source, err := file.open()
+    if err != nil {
+        return "", err
+    }
+    defer source.Close()
+
+    output, err := installer.target.OpenFile(destination, os.O_WRONLY | os.O_CREATE | os.O_TRUNC, category_permissions(file.filetype))
+    if err != nil {
+        return "", err
+    }
+
+    hasher := sha256.New()
+    _, err = io.CopyBuffer(io.MultiWriter(output, hasher), source, make([]byte, copy_buffer_size))
+    close_err := output.Close()
+    if err != nil {
+        return "", err
+    }
+    if close_err != nil {
+        return "", close_err
+    }
+
+    return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+/*
+    This method returns the path to write a configuration
+    file, local changes are preserved: a file modified since
+    the previous install is kept and the new version is
+    written next to it with the ".new" extension.
+*/
+func (installer *Installer) config_destination(path string, file File) (string, string) {
+    current, err := installer.hash_file(path)
+    if err != nil {
+        return path, ""
+    }
+
+    if file.remote != nil {
+        if file.remote.Hash == current {
+            return path, ""
+        }
+    } else if source, err := file.open(); err == nil {
+        shipped, err := hash_reader(source)
+        source.Close()
+        if err == nil && shipped == current {
+            return path, ""
+        }
+    }
+
+    for _, entry := range installer.previous_receipt.Files {
+        if entry.Path == path && entry.Hash == current {
+            return path, ""
+        }
+    }
+
+    return path + ".new", "Configuration file modified locally, kept: " + path
+}
+
+/*
+    This function returns the SHA256 hexadecimal digest.
+*/
+func hash_data(data []byte) string {
+    digest := sha256.Sum256(data)
+    return hex.EncodeToString(digest[:])
+}
+
+/*
+    This method returns the SHA256 of a file.
+*/
+func (installer *Installer) hash_file(path string) (string, error) {
+    file, err := installer.target.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+    return hash_reader(file)
+}
+
+/*
+    This function returns the SHA256 of a reader content.
+*/
+func hash_reader(reader io.Reader) (string, error) {
+    hasher := sha256.New()
+    _, err := io.CopyBuffer(hasher, reader, make([]byte, copy_buffer_size))
+    if err != nil {
+        return "", err
+    }
+    return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+/*
+    This method executes system commands when is
+    required for the software install.
+*/
+func (installer *Installer) run_commands(ctx context.Context) {
+    for _, command := range installer.install_commands(runtime.GOOS) {
+        err := installer.run_command(ctx, command)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Command error: %v\n", err)
+        }
+    }
+}
+
+/*
+    This method executes a system command with
+    variables in its environment (Options.CommandRunner),
+    a skipped command is not an error.
+*/
+func (installer *Installer) run_command(ctx context.Context, command string) error {
+    out, err := installer.commands.Run(ctx, command, installer.variables_environment())
+    if errors.Is(err, Skipped) {
+        fmt.Printf("Command skipped: %s\n", command)
+        return nil
+    }
+
+    fmt.Printf("Ouput: %s\n", string(out))
+    return err
+}
+
+/*
+    This method returns post-install commands of an operating
+    system with variables values (Options and manifest commands,
+    then commands of installed components), packages scripts
+    use the Linux commands.
+*/
+func (installer *Installer) install_commands(goos string) []string {
+    var commands []string
+
+    if goos == "windows" {
+        commands = append(commands, installer.options.WindowsCommands...)
+        commands = append(commands, installer.manifest.WindowsCommands...)
+    } else {
+        commands = append(commands, installer.options.LinuxCommands...)
+        commands = append(commands, installer.manifest.LinuxCommands...)
+    }
+    commands = append(commands, installer.component_commands(goos)...)
+
+    for index, command := range commands {
+        commands[index] = installer.expand_variables(command)
+    }
+    return commands
+}
+
+/*
+    This function checks if process have privileges
+    to install the software.
+*/
+func check_privileges() (bool, error) {
+    switch runtime.GOOS {
+    case "windows":
+        return check_administrator()
+    default:
+        return check_root()
+    }
+}
+
+/*
+    This function returns the install receipt path.
+*/
+func receipt_path(data_directory string) string {
+    return filepath.Join(data_directory, ".goinstaller", "receipt.json")
+}
+
+/*
+    This method reads an install receipt.
+*/
+func (installer *Installer) load_receipt(path string) (Receipt, error) {
+    var installed Receipt
+    content, err := read_target(installer.target, path)
+    if err != nil {
+        return installed, err
+    }
+
+    err = json.Unmarshal(content, &installed)
+    return installed, err
+}
+
+/*
+    This method writes the install receipt, it lists
+    everything the uninstaller should remove. Directories
+    created by a previous install are kept in the receipt.
+*/
+func (installer *Installer) save_receipt(data_directory string) error {
+    path := receipt_path(data_directory)
+    err := installer.create_directory(filepath.Dir(path))
+    if err != nil {
+        return err
+    }
+
+    var directories []ReceiptFile
+    for _, entry := range installer.previous_receipt.Files {
+        if entry.Category == "directory" && installer.file_exists(entry.Path) && !installer.receipt_contains(entry.Path) {
+            directories = append(directories, entry)
+        }
+    }
+    installer.receipt.Files = append(directories, installer.receipt.Files...)
+
+    content, err := json.MarshalIndent(installer.receipt, "", "    ")
+    if err != nil {
+        return failure(ExitWrite, "encoding receipt: %v", err)
+    }
+
+    err = write_target(installer.target, path, content, 0644)
+    if err != nil {
+        return failure(ExitWrite, "writing receipt %s: %v", path, err)
+    }
+    return nil
+}
+
+/*
+    This method checks if a path is in the install receipt.
+*/
+func (installer *Installer) receipt_contains(path string) bool {
+    for _, entry := range installer.receipt.Files {
+        if entry.Path == path {
+            return true
+        }
+    }
+    return false
+}
+
+/*
+    This method removes files listed in the install receipt,
+    the receipt (and its directories) is removed last: an
+    interrupted uninstall keeps entries not yet removed.
+    Installed services are stopped and disabled before
+    their files are removed.
+*/
+func (installer *Installer) Uninstall(ctx context.Context) error {
+    err := installer.require_privileges()
+    if err != nil {
+        return err
+    }
+
+    lock, err := installer.lock(ctx)
+    if err != nil {
+        return err
+    }
+    defer lock.release()
+
+    path := receipt_path(installer.get_layout().Data)
+    installed, err := installer.load_receipt(path)
+    if err != nil {
+        return failure(ExitReceipt, "loading receipt %s: %v", path, err)
+    }
+
+    services := installer.owned_services(installed)
+    installer.disable_services(ctx, services)
+
+    var parents []ReceiptFile
+    for index := len(installed.Files) - 1; index >= 0; index-- {
+        if ctx.Err() != nil {
+            installer.keep_receipt(path, installed, installed.Files[:index + 1], parents)
+            return failure(ExitReceipt, "uninstall interrupted: %v", ctx.Err())
+        }
+
+        entry := installed.Files[index]
+        if entry.Category == "directory" && strings.HasPrefix(path, entry.Path + string(filepath.Separator)) {
+            parents = append(parents, entry)
+            continue
+        }
+        installer.remove_receipt_entry(entry)
+    }
+
+    installer.target.Remove(path)
+    for _, entry := range parents {
+        installer.remove_receipt_entry(entry)
+    }
+
+    if len(services) > 0 {
+        err = installer.services.Reload(ctx)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error reloading services: %v\n", err)
+        }
+    }
+    return nil
+}
+
+/*
+    This method rewrites the receipt of an interrupted
+    uninstall with entries not yet removed and receipt
+    directories (removed last), the uninstall can be
+    run again.
+*/
+func (installer *Installer) keep_receipt(path string, installed Receipt, remaining []ReceiptFile, parents []ReceiptFile) {
+    installed.Files = append([]ReceiptFile{}, remaining...)
+    for index := len(parents) - 1; index >= 0; index-- {
+        installed.Files = append(installed.Files, parents[index])
+    }
+
+    content, err := json.MarshalIndent(installed, "", "    ")
+    if err == nil {
+        err = write_target(installer.target, path, content, 0644)
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error writing receipt %s: %v\n", path, err)
+    }
+}
+
+/*
+    This method removes an installed file, data files are
+    kept, links are removed only when they still point to
+    the installed file, configuration files only when they
+    are not modified and directories only when they are empty.
+*/
+func (installer *Installer) remove_receipt_entry(entry ReceiptFile) {
+    if entry.Category == "data" {
+        return
+    }
+
+    if entry.Category == "directory" {
+        if installer.target.Remove(entry.Path) == nil {
+            fmt.Printf("Removed: %s\n", entry.Path)
+        }
+        return
+    }
+
+    if entry.Category == "config" {
+        current, err := installer.hash_file(entry.Path)
+        if err == nil && current != entry.Hash {
+            fmt.Printf("Configuration file modified locally, not removed: %s\n", entry.Path)
+            return
+        }
+    }
+
+    if entry.Category == "link" {
+        target, err := installer.target.Readlink(entry.Path)
+        if err != nil || target != entry.Target {
+            fmt.Printf("Link changed, not removed: %s\n", entry.Path)
+            return
+        }
+    }
+
+    err := installer.target.Remove(entry.Path)
+    if err != nil && !errors.Is(err, os.ErrNotExist) {
+        fmt.Fprintf(os.Stderr, "Error removing %s: %v\n", entry.Path, err)
+        return
+    }
+    fmt.Printf("Removed: %s\n", entry.Path)
+}
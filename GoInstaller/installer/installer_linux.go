@@ -0,0 +1,479 @@
+/*
+    This file implements Linux specific features for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+//go:build linux
+// +build linux
+
+package installer
+
+import (
+    "path/filepath"
+    "context"
+    "os/exec"
+    "strings"
+    "syscall"
+    "unsafe"
+    "time"
+    "fmt"
+    "os"
+)
+
+/*
+    This function checks for privileges on Linux.
+*/
+func check_root() (bool, error) {
+    return os.Geteuid() == 0, nil
+}
+
+/*
+    Privileges elevation tools, in preference order.
+*/
+var elevation_tools = []string{"sudo", "doas", "pkexec"}
+
+/*
+    This function returns the command line re-executing
+    the installer with the first available elevation tool.
+    A shell restores the working directory (pkexec changes
+    it) and env sets environment variables (elevation tools
+    reset the environment).
+*/
+func elevation_command(executable string, directory string, arguments []string, environment []string) ([]string, error) {
+    for _, name := range elevation_tools {
+        tool, err := exec.LookPath(name)
+        if err != nil {
+            continue
+        }
+
+        command := []string{tool, "/bin/sh", "-c", `cd -- "$1" && shift && exec env "$@"`, "goinstaller", directory}
+        command = append(command, environment...)
+        command = append(command, executable)
+        return append(command, arguments...), nil
+    }
+    return nil, fmt.Errorf("no %s found, run the installer as root", strings.Join(elevation_tools, ", "))
+}
+
+/*
+    This method configures the rotation of the application
+    log directory from the manifest "logrotate" settings.
+
+    A logrotate policy is written in /etc/logrotate.d, on
+    systemd hosts without logrotate a journald drop-in limits
+    the journal (retention, file size and compression) where
+    services should log, systemd-journald is restarted to
+    load it when the target is the system.
+*/
+func (installer *Installer) configure_log_rotation(ctx context.Context, log_directory string) {
+    settings := installer.manifest.LogRotate
+    if settings == nil {
+        return
+    }
+
+    rotate := settings.Rotate
+    if rotate <= 0 {
+        rotate = 4
+    }
+
+    backend := installer.log_rotation_backend()
+    if backend == "" {
+        fmt.Fprintf(os.Stderr, "No log rotation system found, %s is not rotated.\n", log_directory)
+        return
+    }
+
+    policy_file := installer.log_rotation_file(backend)
+    err := installer.target.MkdirAll(filepath.Dir(policy_file), 0755)
+    if err == nil && backend == "logrotate" {
+        err = installer.write_generated_file(policy_file, installer.logrotate_policy(log_directory, *settings, rotate), "logrotate")
+    } else if err == nil {
+        err = installer.write_generated_file(policy_file, installer.journald_policy(*settings, rotate), "logrotate")
+        if err == nil && installer.system_target() {
+            err = systemd_manager{}.systemctl(ctx, "try-restart", "systemd-journald")
+        }
+    }
+
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error configuring log rotation: %v\n", err)
+    }
+}
+
+/*
+    This method returns the log rotation backend of the
+    target: "logrotate", "journald" on systemd hosts without
+    logrotate or an empty string.
+*/
+func (installer *Installer) log_rotation_backend() string {
+    if installer.manifest.LogRotate == nil {
+        return ""
+    } else if installer.logrotate_installed() {
+        return "logrotate"
+    } else if installer.file_exists("/run/systemd/system") {
+        return "journald"
+    }
+    return ""
+}
+
+/*
+    This method returns the journald drop-in keeping the
+    journal for the rotation period, journal files are
+    limited to max_size (compression is the journald
+    default and is only forced).
+*/
+func (installer *Installer) journald_policy(settings LogRotate, rotate int) string {
+    policy := generated_marker + " for " + installer.name + ", removed on uninstall.\n" +
+        "[Journal]\n" +
+        fmt.Sprintf("MaxRetentionSec=%dday\n", rotation_days[settings.Frequency] * rotate)
+
+    if settings.MaxSize != "" {
+        policy += "SystemMaxFileSize=" + strings.ToUpper(settings.MaxSize) + "\n"
+    }
+
+    if settings.Compress {
+        policy += "Compress=yes\n"
+    }
+    return policy
+}
+
+/*
+    This method checks if logrotate is installed, the PATH
+    is searched only when the target is the system.
+*/
+func (installer *Installer) logrotate_installed() bool {
+    if installer.file_exists("/usr/sbin/logrotate") {
+        return true
+    }
+    if !installer.system_target() {
+        return false
+    }
+
+    _, err := exec.LookPath("logrotate")
+    return err == nil
+}
+
+/*
+    This method returns the logrotate policy for the log directory.
+*/
+func (installer *Installer) logrotate_policy(log_directory string, settings LogRotate, rotate int) string {
+    pattern := settings.Pattern
+    if pattern == "" {
+        pattern = "*.log"
+    }
+
+    frequency := settings.Frequency
+    if frequency == "" {
+        frequency = "weekly"
+    }
+
+    policy := generated_marker + " for " + installer.name + ", removed on uninstall.\n" +
+        filepath.Join(log_directory, pattern) + " {\n" +
+        "    " + frequency + "\n" +
+        fmt.Sprintf("    rotate %d\n", rotate) +
+        "    missingok\n" +
+        "    notifempty\n"
+
+    if settings.MaxSize != "" {
+        policy += "    maxsize " + settings.MaxSize + "\n"
+    }
+
+    if settings.Compress {
+        policy += "    compress\n    delaycompress\n"
+    }
+
+    if settings.CopyTruncate {
+        policy += "    copytruncate\n"
+    } else if settings.PostRotate != "" {
+        policy += "    sharedscripts\n    postrotate\n        " + settings.PostRotate + "\n    endscript\n"
+    }
+
+    return policy + "}\n"
+}
+
+/*
+    This method writes a file generated by the installer
+    and saves it in the install receipt, a file not
+    generated by GoInstaller is never overwritten.
+*/
+func (installer *Installer) write_generated_file(path string, content string, category string) error {
+    existing, err := read_target(installer.target, path)
+    if err == nil && !strings.HasPrefix(string(existing), generated_marker) {
+        return fmt.Errorf("existing file not overwritten: %s", path)
+    }
+
+    err = write_target(installer.target, path, []byte(content), 0644)
+    if err != nil {
+        return fmt.Errorf("writing file %s: %v", path, err)
+    }
+
+    fmt.Printf("Installed: %s\n", path)
+    installer.receipt.Files = append(installer.receipt.Files, ReceiptFile{Path: path, Category: category})
+    return nil
+}
+
+/*
+    This function checks if the standard input is a terminal.
+*/
+func is_terminal() bool {
+    var termios syscall.Termios
+    _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, os.Stdin.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+    return errno == 0
+}
+
+/*
+    This function returns the default services start
+    policy, on Linux services are managed by commands.
+*/
+func default_service_start() string {
+    return "none"
+}
+
+/*
+    This method enables (and starts) installed systemd
+    units with the "enable" and "start" policies.
+*/
+func (installer *Installer) start_services(ctx context.Context) {
+    if installer.options.ServiceStart == "" || installer.options.ServiceStart == "none" {
+        return
+    }
+
+    var units []string
+    for _, entry := range installer.receipt.Files {
+        name := filepath.Base(entry.Path)
+        if entry.Category == "service" && systemd_unit(name) {
+            units = append(units, name)
+        }
+    }
+
+    if len(units) == 0 {
+        return
+    }
+
+    err := installer.services.Reload(ctx)
+    if err == nil {
+        err = installer.services.Enable(ctx, units, installer.options.ServiceStart == "start")
+    }
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error enabling services: %v\n", err)
+        return
+    }
+
+    if installer.options.ServiceStart == "start" {
+        installer.started_services = units
+    }
+    fmt.Printf("Services %s: %s\n", installer.options.ServiceStart, strings.Join(units, ", "))
+}
+
+/*
+    The systemd service manager (systemctl).
+*/
+type systemd_manager struct{}
+
+/*
+    This function returns the system service manager.
+*/
+func default_service_manager() ServiceManager {
+    return systemd_manager{}
+}
+
+/*
+    This method runs systemctl, the output is in the error.
+*/
+func (systemd_manager) systemctl(ctx context.Context, arguments ...string) error {
+    out, err := exec.CommandContext(ctx, "systemctl", arguments...).CombinedOutput()
+    if err != nil {
+        return fmt.Errorf("systemctl %s: %v %s", strings.Join(arguments, " "), err, strings.TrimSpace(string(out)))
+    }
+    return nil
+}
+
+/*
+    This method checks if a unit is active, units are
+    never active without systemd.
+*/
+func (manager systemd_manager) Active(ctx context.Context, name string) (bool, error) {
+    if _, err := exec.LookPath("systemctl"); err != nil {
+        return false, nil
+    }
+
+    err := exec.CommandContext(ctx, "systemctl", "is-active", "--quiet", name).Run()
+    if _, ok := err.(*exec.ExitError); ok {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+/*
+    This method stops a unit and waits until the timeout.
+*/
+func (manager systemd_manager) Stop(ctx context.Context, name string, timeout time.Duration) error {
+    stop_context, cancel := context.WithTimeout(ctx, timeout)
+    defer cancel()
+
+    err := manager.systemctl(stop_context, "stop", name)
+    if stop_context.Err() == context.DeadlineExceeded {
+        return fmt.Errorf("not stopped after %s", timeout)
+    }
+    return err
+}
+
+/*
+    This method starts a unit.
+*/
+func (manager systemd_manager) Start(ctx context.Context, name string) error {
+    return manager.systemctl(ctx, "start", name)
+}
+
+/*
+    This method enables (and starts) units.
+*/
+func (manager systemd_manager) Enable(ctx context.Context, names []string, start bool) error {
+    arguments := append([]string{"enable"}, names...)
+    if start {
+        arguments = append([]string{"enable", "--now"}, names...)
+    }
+    return manager.systemctl(ctx, arguments...)
+}
+
+/*
+    This method stops and disables units.
+*/
+func (manager systemd_manager) Disable(ctx context.Context, names []string) error {
+    return manager.systemctl(ctx, append([]string{"disable", "--now"}, names...)...)
+}
+
+/*
+    This method reloads units files.
+*/
+func (manager systemd_manager) Reload(ctx context.Context) error {
+    return manager.systemctl(ctx, "daemon-reload")
+}
+
+/*
+    This method adds the GUI program to the Windows menu.
+*/
+func (installer *Installer) add_to_windows_menu(executable_path string) {}
+
+/*
+    This method adds the program path to the SYSTEM environment variables (for all users).
+
+    Programs listed in the manifest "commands" are linked
+    in /usr/local/bin, with the "profile" path mode an
+    /etc/profile.d script adds the directory to the PATH.
+    Nothing is done when programs are in /usr/local/bin.
+*/
+func (installer *Installer) add_to_system_path(new_path string) error {
+    if filepath.Clean(new_path) == linux_binaries_directory {
+        return nil
+    }
+
+    if installer.manifest.PathMode == "profile" {
+        return installer.write_profile_script(new_path)
+    }
+
+    for _, command := range installer.manifest.Commands {
+        installer.link_command(new_path, command)
+    }
+    return nil
+}
+
+/*
+    This method links a program in /usr/local/bin,
+    existing unrelated files are never overwritten.
+*/
+func (installer *Installer) link_command(program_directory string, command string) {
+    target := filepath.Join(program_directory, command)
+    link := filepath.Join(linux_binaries_directory, command)
+
+    if !installer.file_exists(target) {
+        fmt.Fprintf(os.Stderr, "Command %s is not an installed program: %s\n", command, target)
+        return
+    }
+
+    if _, err := installer.target.Lstat(link); err == nil {
+        existing, err := installer.target.Readlink(link)
+        if err != nil || existing != target {
+            fmt.Fprintf(os.Stderr, "Existing file not overwritten: %s\n", link)
+            return
+        }
+    } else if err := installer.target.Symlink(target, link); err != nil {
+        fmt.Fprintf(os.Stderr, "Error linking %s: %v\n", link, err)
+        return
+    }
+
+    fmt.Printf("Linked: %s -> %s\n", link, target)
+    installer.receipt.Files = append(installer.receipt.Files, ReceiptFile{Path: link, Category: "link", Target: target})
+}
+
+/*
+    This method writes an /etc/profile.d script adding the
+    program directory to the PATH, a script not generated
+    by GoInstaller is never overwritten.
+*/
+func (installer *Installer) write_profile_script(program_directory string) error {
+    path := filepath.Join("/etc/profile.d", installer.name + ".sh")
+    return installer.write_generated_file(path, installer.profile_script(program_directory), "profile")
+}
+
+/*
+    This method creates and starts a service on Windows.
+*/
+func (installer *Installer) create_service(executable_path string) {}
+
+/*
+    This function checks for privileges on Windows.
+*/
+func check_administrator() (bool, error) {
+    return false, nil
+}
+
+/*
+    This function executes Windows commands.
+*/
+func execute_windows_command (ctx context.Context, command string) *exec.Cmd {
+    return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+/*
+    This function creates the application source log in Windows event source log.
+*/
+func add_application_source_log (application string) {}
+
+/*
+    This function returns the install lock path, /run is
+    a tmpfs: locks are removed on reboot.
+*/
+func lock_path(application string) string {
+    return filepath.Join("/run/goinstaller", application + ".lock")
+}
+
+/*
+    This function takes an exclusive flock without waiting,
+    it returns false when the lock is held by another process.
+*/
+func try_lock(file *os.File) (bool, error) {
+    err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX | syscall.LOCK_NB)
+    if err == syscall.EWOULDBLOCK {
+        return false, nil
+    }
+    return err == nil, err
+}
+
+/*
+    This function checks if a process is running.
+*/
+func process_alive(pid int) bool {
+    err := syscall.Kill(pid, 0)
+    return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,485 @@
+/*
+    This file tests installs on an in-memory filesystem for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "testing/fstest"
+    "encoding/json"
+    "reflect"
+    "strings"
+    "context"
+    "runtime"
+    "testing"
+    "errors"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+/*
+    This function returns an installer of a test payload
+    for a target, the manifest is optional.
+*/
+func new_test_installer(t *testing.T, manifest string, payload fstest.MapFS, target FileSystem) *Installer {
+    t.Helper()
+    if manifest != "" {
+        payload["manifest.json"] = &fstest.MapFile{Data: []byte(manifest)}
+    }
+
+    setup, err := New(Options{Name: "testapp", Payload: payload, Yes: true, FileSystem: target})
+    if err != nil {
+        t.Fatalf("New: %v", err)
+    }
+    return setup
+}
+
+/*
+    This function returns a payload file.
+*/
+func test_file(content string) *fstest.MapFile {
+    return &fstest.MapFile{Data: []byte(content), Mode: 0644}
+}
+
+/*
+    This function tests installs and uninstalls on the
+    in-memory filesystem: installed files, kept data and
+    modified config files, skipped commands and the zero
+    value of MemoryFileSystem.
+*/
+func TestInstallUninstallMemoryFileSystem(t *testing.T) {
+    marker := filepath.Join(t.TempDir(), "marker")
+    commands, _ := json.Marshal([]string{"echo command > " + marker})
+
+    tests := []struct {
+        name string
+        manifest string
+        payload fstest.MapFS
+        zero bool
+        modify map[string]string
+        installed []string
+        kept []string
+    }{
+        {
+            name: "program and config",
+            payload: fstest.MapFS{"program/app": test_file("binary"), "config/app.conf": test_file("key=value")},
+            installed: []string{"bin/app", "config/app.conf"},
+        },
+        {
+            name: "zero value filesystem",
+            payload: fstest.MapFS{"program/app": test_file("binary")},
+            zero: true,
+            installed: []string{"bin/app"},
+        },
+        {
+            name: "data files are kept",
+            payload: fstest.MapFS{"program/app": test_file("binary"), "data/state.db": test_file("state")},
+            installed: []string{"bin/app", "data/state.db"},
+            kept: []string{"data/state.db"},
+        },
+        {
+            name: "modified config files are kept",
+            payload: fstest.MapFS{"config/app.conf": test_file("key=value")},
+            modify: map[string]string{"config/app.conf": "key=local"},
+            installed: []string{"config/app.conf"},
+            kept: []string{"config/app.conf"},
+        },
+        {
+            name: "commands are skipped",
+            manifest: `{"linux_commands": ` + string(commands) + `, "windows_commands": ` + string(commands) + `}`,
+            payload: fstest.MapFS{"program/app": test_file("binary")},
+            installed: []string{"bin/app"},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            target := NewMemoryFileSystem()
+            if test.zero {
+                target = &MemoryFileSystem{}
+            }
+
+            setup := new_test_installer(t, test.manifest, test.payload, target)
+            err := setup.Install(context.Background())
+            if err != nil {
+                t.Fatalf("Install: %v", err)
+            }
+
+            layout := setup.get_layout()
+            directories := map[string]string{"bin": layout.Bin, "config": layout.Config, "data": layout.Data}
+            destination := func(name string) string {
+                return filepath.Join(directories[filepath.Dir(name)], filepath.Base(name))
+            }
+
+            for _, name := range test.installed {
+                if _, err := target.Stat(destination(name)); err != nil {
+                    t.Errorf("%s is not installed: %v", name, err)
+                }
+            }
+            if _, err := target.Stat(receipt_path(layout.Data)); err != nil {
+                t.Errorf("no install receipt: %v", err)
+            }
+            if _, err := os.Stat(marker); err == nil {
+                t.Errorf("a command has been executed for the in-memory target")
+            }
+
+            for name, content := range test.modify {
+                err = write_target(target, destination(name), []byte(content), 0644)
+                if err != nil {
+                    t.Fatalf("modifying %s: %v", name, err)
+                }
+            }
+
+            err = setup.Uninstall(context.Background())
+            if err != nil {
+                t.Fatalf("Uninstall: %v", err)
+            }
+
+            for _, name := range test.installed {
+                _, err := target.Stat(destination(name))
+                if kept := contains(test.kept, name); kept != (err == nil) {
+                    t.Errorf("%s: kept is %t after uninstall, expected %t", name, err == nil, kept)
+                }
+            }
+            if _, err := target.Stat(receipt_path(layout.Data)); err == nil {
+                t.Errorf("the install receipt is not removed")
+            }
+        })
+    }
+}
+
+/*
+    This function tests the in-memory filesystem
+    operations used by the installer.
+*/
+func TestMemoryFileSystem(t *testing.T) {
+    tests := []struct {
+        name string
+        run func(files *MemoryFileSystem) error
+        expected []string
+        fails bool
+    }{
+        {
+            name: "write without parent",
+            run: func(files *MemoryFileSystem) error {
+                return write_target(files, "/missing/file", []byte("data"), 0644)
+            },
+            fails: true,
+        },
+        {
+            name: "write and link",
+            run: func(files *MemoryFileSystem) error {
+                err := files.MkdirAll("/a/b", 0755)
+                if err == nil {
+                    err = write_target(files, "/a/b/file", []byte("data"), 0644)
+                }
+                if err == nil {
+                    err = files.Symlink("/a/b/file", "/a/link")
+                }
+                return err
+            },
+            expected: []string{"/a", "/a/b", "/a/b/file", "/a/link"},
+        },
+        {
+            name: "remove a non empty directory",
+            run: func(files *MemoryFileSystem) error {
+                err := files.MkdirAll("/a/b", 0755)
+                if err == nil {
+                    err = files.Remove("/a")
+                }
+                return err
+            },
+            fails: true,
+        },
+        {
+            name: "rename a directory",
+            run: func(files *MemoryFileSystem) error {
+                err := files.MkdirAll("/a/b", 0755)
+                if err == nil {
+                    err = files.Rename("/a", "/c")
+                }
+                return err
+            },
+            expected: []string{"/c", "/c/b"},
+        },
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            files := &MemoryFileSystem{}
+            err := test.run(files)
+            if test.fails != (err != nil) {
+                t.Fatalf("error: %v, expected failure: %t", err, test.fails)
+            }
+
+            paths := files.Paths()
+            if !test.fails && len(paths) != len(test.expected) {
+                t.Fatalf("paths %v, expected %v", paths, test.expected)
+            }
+            for index := range test.expected {
+                if paths[index] != filepath.FromSlash(test.expected[index]) {
+                    t.Errorf("paths %v, expected %v", paths, test.expected)
+                }
+            }
+        })
+    }
+}
+
+/*
+    This function tests the worker pool writing files:
+    receipt entries are in the payload order, files written
+    after a failed file are in the receipt (the rollback
+    removes them) and a cancelled install writes nothing.
+*/
+func TestInstallFilesWorkers(t *testing.T) {
+    tests := []struct {
+        name string
+        failed int
+        cancelled bool
+    }{
+        {name: "payload order", failed: -1},
+        {name: "failed file", failed: 3},
+        {name: "first file failed", failed: 0},
+        {name: "cancelled install", failed: -1, cancelled: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            target := NewMemoryFileSystem()
+            setup := new_test_installer(t, `{"workers": 4}`, fstest.MapFS{}, target)
+            directory := filepath.FromSlash("/opt/testapp")
+            err := target.MkdirAll(directory, 0755)
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            var files []File
+            for index := 0; index < 24; index++ {
+                index := index
+                files = append(files, File{
+                    filetype: "program",
+                    path: directory,
+                    name: fmt.Sprintf("file%02d", index),
+                    open: func() (io.ReadCloser, error) {
+                        if index == test.failed {
+                            return nil, errors.New("unreadable payload file")
+                        }
+                        time.Sleep(time.Duration(24 - index) * time.Millisecond / 4)
+                        return io.NopCloser(strings.NewReader(fmt.Sprint(index))), nil
+                    },
+                })
+            }
+
+            ctx, cancel := context.WithCancel(context.Background())
+            defer cancel()
+            if test.cancelled {
+                cancel()
+            }
+
+            err = setup.install_files(ctx, files)
+            if (test.failed >= 0 || test.cancelled) != (err != nil) {
+                t.Fatalf("install_files: %v", err)
+            }
+
+            recorded := make(map[string]bool)
+            for index, entry := range setup.receipt.Files {
+                recorded[entry.Path] = true
+                if err == nil && entry.Path != filepath.Join(directory, files[index].name) {
+                    t.Errorf("receipt entry %d is %s, expected the payload order", index, entry.Path)
+                }
+            }
+
+            written := 0
+            for _, path := range target.Paths() {
+                if path == directory || filepath.Dir(path) != directory {
+                    continue
+                }
+                written++
+                if !recorded[path] {
+                    t.Errorf("%s is written but not in the receipt", path)
+                }
+            }
+
+            if err == nil && written != len(files) {
+                t.Errorf("%d files written, expected %d", written, len(files))
+            }
+            if test.cancelled && written != 0 {
+                t.Errorf("%d files written by a cancelled install", written)
+            }
+        })
+    }
+}
+
+/*
+    A service manager recording calls, unit files
+    must exist when services are disabled.
+*/
+type recording_service_manager struct {
+    NoServiceManager
+    target FileSystem
+    files []string
+    calls []string
+}
+
+/*
+    This method records disabled services and
+    checks their files are not yet removed.
+*/
+func (manager *recording_service_manager) Disable(ctx context.Context, names []string) error {
+    for _, path := range manager.files {
+        if _, err := manager.target.Stat(path); err != nil {
+            manager.calls = append(manager.calls, "removed before disable: " + path)
+        }
+    }
+    manager.calls = append(manager.calls, "disable " + strings.Join(names, " "))
+    return nil
+}
+
+/*
+    This method records enabled services.
+*/
+func (manager *recording_service_manager) Enable(ctx context.Context, names []string, start bool) error {
+    manager.calls = append(manager.calls, fmt.Sprintf("enable %s %t", strings.Join(names, " "), start))
+    return nil
+}
+
+/*
+    This method records services reloads.
+*/
+func (manager *recording_service_manager) Reload(ctx context.Context) error {
+    manager.calls = append(manager.calls, "reload")
+    return nil
+}
+
+/*
+    This function tests that an uninstall stops and disables
+    installed services before removing their files and
+    reloads the service manager after.
+*/
+func TestUninstallServices(t *testing.T) {
+    service := "app.service"
+    if runtime.GOOS == "windows" {
+        service = "testapp"
+    }
+
+    tests := []struct {
+        name string
+        payload fstest.MapFS
+        expected []string
+    }{
+        {name: "installed service", payload: fstest.MapFS{"service/app.service": test_file("[Service]")}, expected: []string{"disable " + service, "reload"}},
+        {name: "no service", payload: fstest.MapFS{"program/app": test_file("binary")}},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            target := NewMemoryFileSystem()
+            setup := new_test_installer(t, "", test.payload, target)
+            err := setup.Install(context.Background())
+            if err != nil {
+                t.Fatalf("Install: %v", err)
+            }
+
+            manager := &recording_service_manager{target: target}
+            for _, entry := range setup.receipt.Files {
+                if entry.Category == "service" {
+                    manager.files = append(manager.files, entry.Path)
+                }
+            }
+
+            setup.services = manager
+            err = setup.Uninstall(context.Background())
+            if err != nil {
+                t.Fatalf("Uninstall: %v", err)
+            }
+            if !reflect.DeepEqual(manager.calls, test.expected) {
+                t.Errorf("service manager calls %q, expected %q", manager.calls, test.expected)
+            }
+            for _, path := range manager.files {
+                if _, err := target.Stat(path); err == nil {
+                    t.Errorf("%s is not removed", path)
+                }
+            }
+        })
+    }
+}
+
+/*
+    This function tests files in shared directories on an
+    upgrade of an install without receipt: existing files
+    are kept without Options.Adopt, adopted files are
+    replaced and restored when the install is rolled back.
+*/
+func TestAdoptFiles(t *testing.T) {
+    tests := []struct {
+        name string
+        adopt bool
+        rollback bool
+        expected string
+        fails bool
+    }{
+        {name: "existing file is kept", expected: "previous unit", fails: true},
+        {name: "existing file is adopted", adopt: true, expected: "[Service]"},
+        {name: "adopted file is restored", adopt: true, rollback: true, expected: "previous unit", fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            target := NewMemoryFileSystem()
+            setup := new_test_installer(t, "", fstest.MapFS{"service/testapp.service": test_file("[Service]")}, target)
+            setup.options.Adopt = test.adopt
+
+            directory := setup.category_directory(setup.get_layout(), "service")
+            if !setup.shared_directory(directory) {
+                t.Skipf("%s is not a shared directory", directory)
+            }
+
+            destination := filepath.Join(directory, "testapp.service")
+            err := target.MkdirAll(directory, 0755)
+            if err == nil {
+                err = write_target(target, destination, []byte("previous unit"), 0644)
+            }
+            if err == nil && test.rollback {
+                err = setup.AddStepAfter("files", Step{
+                    Name: "failure",
+                    Apply: func(ctx context.Context, installer *Installer) error {
+                        return errors.New("step failure")
+                    },
+                })
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            err = setup.Install(context.Background())
+            if test.fails != (err != nil) {
+                t.Fatalf("Install: %v, expected failure: %t", err, test.fails)
+            }
+
+            content, err := target.ReadFile(destination)
+            if err != nil || string(content) != test.expected {
+                t.Errorf("%s content is %q (%v), expected %q", destination, content, err, test.expected)
+            }
+            if _, err := target.Stat(destination + backup_extension); err == nil {
+                t.Errorf("the backup of %s is not removed", destination)
+            }
+        })
+    }
+}
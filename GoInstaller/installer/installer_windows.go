@@ -19,13 +19,16 @@
 //go:build windows
 // +build windows
 
-package main
+package installer
 
 import (
+    "path/filepath"
+    "context"
     "os/exec"
     "syscall"
     "strings"
     "unsafe"
+    "time"
     "fmt"
     "os"
 )
@@ -45,6 +48,20 @@ const (
     REG_EXPAND_SZ               = 2
     REG_DWORD                   = 4
     MAX_PATH                    = 256
+    LOCKFILE_FAIL_IMMEDIATELY   = 0x00000001
+    LOCKFILE_EXCLUSIVE_LOCK     = 0x00000002
+    ERROR_LOCK_VIOLATION        = 33
+    PROCESS_QUERY_LIMITED_INFORMATION = 0x1000
+    STILL_ACTIVE                = 259
+    SC_MANAGER_CONNECT          = 0x00000001
+    SERVICE_QUERY_STATUS        = 0x00000004
+    SERVICE_START               = 0x00000010
+    SERVICE_STOP                = 0x00000020
+    SERVICE_CONTROL_STOP        = 0x00000001
+    SERVICE_STOPPED             = 0x00000001
+    DELETE                      = 0x00010000
+    ERROR_SERVICE_DOES_NOT_EXIST = 1060
+    ERROR_SERVICE_NOT_ACTIVE    = 1062
 )
 
 var (
@@ -56,6 +73,10 @@ var (
     createService             = modAdvapi32.NewProc("CreateServiceW")
     closeServiceHandle        = modAdvapi32.NewProc("CloseServiceHandle")
     startService              = modAdvapi32.NewProc("StartServiceW")
+    openService               = modAdvapi32.NewProc("OpenServiceW")
+    queryServiceStatus        = modAdvapi32.NewProc("QueryServiceStatus")
+    controlService            = modAdvapi32.NewProc("ControlService")
+    deleteService             = modAdvapi32.NewProc("DeleteService")
     regOpenKeyEx              = modAdvapi32.NewProc("RegOpenKeyExW")
     regCreateKeyEx            = modAdvapi32.NewProc("RegCreateKeyEx")
     regCloseKey               = modAdvapi32.NewProc("RegCloseKey")
@@ -64,6 +85,11 @@ var (
     kernel32                  = syscall.NewLazyDLL("kernel32.dll")
     createSymbolicLinkW       = kernel32.NewProc("CreateSymbolicLinkW")
     getSystemDirectory        = kernel32.NewProc("GetSystemDirectory")
+    getConsoleMode            = kernel32.NewProc("GetConsoleMode")
+    lockFileEx                = kernel32.NewProc("LockFileEx")
+    openProcess               = kernel32.NewProc("OpenProcess")
+    getExitCodeProcess        = kernel32.NewProc("GetExitCodeProcess")
+    closeHandle               = kernel32.NewProc("CloseHandle")
 
     SECURITY_NT_AUTHORITY     = [6]byte{0, 0, 0, 0, 0, 5}
 )
@@ -108,16 +134,22 @@ func check_administrator() (bool, error) {
 }
 
 /*
-    This function creates and starts a service on Windows.
+    This method creates and starts a service on Windows,
+    the service is not started with the "enable" services
+    start policy and not created with "none".
 */
-func create_service(executable_path string) {
+func (installer *Installer) create_service(executable_path string) {
+    if installer.options.ServiceStart == "none" {
+        return
+    }
+
     service_manager, _, err := openSCManager.Call(0, 0, uintptr(SC_MANAGER_CREATE_SERVICE))
     if service_manager == 0 {
         fmt.Fprintf(os.Stderr, "failed to open Service Control Manager: %v\n", err)
         return
     }
 
-    service_name_pointer, err := syscall.UTF16PtrFromString(application_name)
+    service_name_pointer, err := syscall.UTF16PtrFromString(installer.name)
     if err != nil {
         fmt.Fprintf(os.Stderr, "failed to generate UTF16 service name: %v\n", err)
         return
@@ -148,6 +180,13 @@ func create_service(executable_path string) {
         return
     }
 
+    if installer.options.ServiceStart == "enable" {
+        closeServiceHandle.Call(service_handle)
+        closeServiceHandle.Call(service_manager)
+        fmt.Printf("Service is created.\n")
+        return
+    }
+
     ret, _, err := startService.Call(service_handle, 0, 0)
     if ret == 0 {
         fmt.Fprintf(os.Stderr, "failed to start service: %v\n", err)
@@ -156,13 +195,13 @@ func create_service(executable_path string) {
 
     closeServiceHandle.Call(service_handle)
     closeServiceHandle.Call(service_manager)
-    fmt.Printf("Service is running.")
+    fmt.Printf("Service is running.\n")
 }
 
 /*
-    This function adds the program path to the SYSTEM environment variables (for all users).
+    This method adds the program path to the SYSTEM environment variables (for all users).
 */
-func add_to_system_path(new_path string) error {
+func (installer *Installer) add_to_system_path(new_path string) error {
     var handle syscall.Handle
     key := syscall.StringToUTF16Ptr(`SYSTEM\CurrentControlSet\Control\Session Manager\Environment`)
     
@@ -214,10 +253,10 @@ func add_string_list_value (list string, new_value string, separator byte) strin
 }
 
 /*
-    This function adds the GUI program to the Windows menu.
+    This method adds the GUI program to the Windows menu.
 */
-func add_to_windows_menu(executable_path string) {
-    shortcut_path := os.Getenv("ProgramData") + "\\Microsoft\\Windows\\Start Menu\\Programs\\" + application_name + ".lnk"
+func (installer *Installer) add_to_windows_menu(executable_path string) {
+    shortcut_path := os.Getenv("ProgramData") + "\\Microsoft\\Windows\\Start Menu\\Programs\\" + installer.name + ".lnk"
     symlink_path_pointer, err := syscall.UTF16PtrFromString(shortcut_path)
     if err != nil {
         fmt.Fprintf(os.Stderr, "failed to get UTF16 symlink path: %v\n", err)
@@ -249,8 +288,8 @@ func add_to_windows_menu(executable_path string) {
 /*
     This function executes Windows commands.
 */
-func execute_windows_command (command string) *exec.Cmd {
-    cmd := exec.Command("cmd.exe")
+func execute_windows_command (ctx context.Context, command string) *exec.Cmd {
+    cmd := exec.CommandContext(ctx, "cmd.exe")
     cmd.SysProcAttr = &syscall.SysProcAttr{
         CmdLine: "C:\\Windows\\System32\\cmd.exe /C " + strings.ReplaceAll(strings.ReplaceAll(command, "^", "^^"), "\"", "^\""),
     }
@@ -323,9 +362,272 @@ func new_registry_key(key_path string, values []RegistryKey) error {
     return nil
 }
 
+/*
+    This function checks if the standard input is a console.
+*/
+func is_terminal() bool {
+    var mode uint32
+    ret, _, _ := getConsoleMode.Call(os.Stdin.Fd(), uintptr(unsafe.Pointer(&mode)))
+    return ret != 0
+}
+
+/*
+    This method configures the rotation of the application log directory on Linux.
+*/
+func (installer *Installer) configure_log_rotation(ctx context.Context, log_directory string) {}
+
+/*
+    This method returns the log rotation backend, logs are not rotated on Windows.
+*/
+func (installer *Installer) log_rotation_backend() string {
+    return ""
+}
+
+/*
+    This function returns the default services start
+    policy, on Windows services are started.
+*/
+func default_service_start() string {
+    return "start"
+}
+
+/*
+    This method starts services on Linux, on Windows
+    services are created when files are installed.
+*/
+func (installer *Installer) start_services(ctx context.Context) {}
+
+/*
+    The Windows Service Control Manager.
+*/
+type windows_service_manager struct{}
+
+/*
+    The status of a Windows service (SERVICE_STATUS).
+*/
+type ServiceStatus struct {
+    ServiceType uint32
+    CurrentState uint32
+    ControlsAccepted uint32
+    Win32ExitCode uint32
+    ServiceSpecificExitCode uint32
+    CheckPoint uint32
+    WaitHint uint32
+}
+
+/*
+    This function returns the system service manager.
+*/
+func default_service_manager() ServiceManager {
+    return windows_service_manager{}
+}
+
+/*
+    This function opens a service, the returned handle is 0
+    without error when the service does not exist. Handles
+    are closed with closeServiceHandle.
+*/
+func open_service(name string, access uintptr) (uintptr, uintptr, error) {
+    service_manager, _, err := openSCManager.Call(0, 0, uintptr(SC_MANAGER_CONNECT))
+    if service_manager == 0 {
+        return 0, 0, fmt.Errorf("failed to open Service Control Manager: %v", err)
+    }
+
+    name_pointer, err := syscall.UTF16PtrFromString(name)
+    if err != nil {
+        closeServiceHandle.Call(service_manager)
+        return 0, 0, err
+    }
+
+    service_handle, _, err := openService.Call(service_manager, uintptr(unsafe.Pointer(name_pointer)), access)
+    if service_handle == 0 {
+        closeServiceHandle.Call(service_manager)
+        if err == syscall.Errno(ERROR_SERVICE_DOES_NOT_EXIST) {
+            return 0, 0, nil
+        }
+        return 0, 0, err
+    }
+    return service_manager, service_handle, nil
+}
+
+/*
+    This function returns the current state of a service.
+*/
+func service_state(service_handle uintptr) (uint32, error) {
+    var status ServiceStatus
+    ret, _, err := queryServiceStatus.Call(service_handle, uintptr(unsafe.Pointer(&status)))
+    if ret == 0 {
+        return 0, err
+    }
+    return status.CurrentState, nil
+}
+
+/*
+    This method checks if a service is running.
+*/
+func (windows_service_manager) Active(ctx context.Context, name string) (bool, error) {
+    service_manager, service_handle, err := open_service(name, SERVICE_QUERY_STATUS)
+    if err != nil || service_handle == 0 {
+        return false, err
+    }
+    defer closeServiceHandle.Call(service_manager)
+    defer closeServiceHandle.Call(service_handle)
+
+    state, err := service_state(service_handle)
+    return err == nil && state != SERVICE_STOPPED, err
+}
+
+/*
+    This method stops a service and waits until the timeout.
+*/
+func (windows_service_manager) Stop(ctx context.Context, name string, timeout time.Duration) error {
+    service_manager, service_handle, err := open_service(name, SERVICE_STOP | SERVICE_QUERY_STATUS)
+    if err != nil || service_handle == 0 {
+        return err
+    }
+    defer closeServiceHandle.Call(service_manager)
+    defer closeServiceHandle.Call(service_handle)
+
+    var status ServiceStatus
+    ret, _, err := controlService.Call(service_handle, SERVICE_CONTROL_STOP, uintptr(unsafe.Pointer(&status)))
+    if ret == 0 && err != syscall.Errno(ERROR_SERVICE_NOT_ACTIVE) {
+        return err
+    }
+
+    deadline := time.Now().Add(timeout)
+    for {
+        state, err := service_state(service_handle)
+        if err != nil || state == SERVICE_STOPPED {
+            return err
+        }
+
+        if time.Now().After(deadline) {
+            return fmt.Errorf("not stopped after %s", timeout)
+        }
+
+        select {
+        case <-time.After(500 * time.Millisecond):
+        case <-ctx.Done():
+            return ctx.Err()
+        }
+    }
+}
+
+/*
+    This method starts a service.
+*/
+func (windows_service_manager) Start(ctx context.Context, name string) error {
+    service_manager, service_handle, err := open_service(name, SERVICE_START)
+    if err != nil {
+        return err
+    }
+    if service_handle == 0 {
+        return fmt.Errorf("service %s does not exist", name)
+    }
+    defer closeServiceHandle.Call(service_manager)
+    defer closeServiceHandle.Call(service_handle)
+
+    ret, _, err := startService.Call(service_handle, 0, 0)
+    if ret == 0 {
+        return err
+    }
+    return nil
+}
+
+/*
+    This method does nothing, on Windows services are
+    created and started when files are installed.
+*/
+func (windows_service_manager) Enable(ctx context.Context, names []string, start bool) error {
+    return nil
+}
+
+/*
+    This method stops and deletes services, services
+    are created by the install.
+*/
+func (manager windows_service_manager) Disable(ctx context.Context, names []string) error {
+    for _, name := range names {
+        err := manager.Stop(ctx, name, default_stop_timeout)
+        if err != nil {
+            return err
+        }
+
+        service_manager, service_handle, err := open_service(name, DELETE)
+        if err != nil || service_handle == 0 {
+            return err
+        }
+
+        ret, _, err := deleteService.Call(service_handle)
+        closeServiceHandle.Call(service_handle)
+        closeServiceHandle.Call(service_manager)
+        if ret == 0 {
+            return err
+        }
+    }
+    return nil
+}
+
+/*
+    This method does nothing, Windows services
+    configurations are not reloaded.
+*/
+func (windows_service_manager) Reload(ctx context.Context) error {
+    return nil
+}
+
+/*
+    This function returns the command line re-executing the
+    installer with privileges on Linux, on Windows the
+    installer is started as administrator.
+*/
+func elevation_command(executable string, directory string, arguments []string, environment []string) ([]string, error) {
+    return nil, fmt.Errorf("run the installer as administrator")
+}
+
 /*
     This function checks for privileges on Linux.
 */
 func check_root() (bool, error) {
     return false, nil
 }
+
+/*
+    This function returns the install lock path.
+*/
+func lock_path(application string) string {
+    return filepath.Join(os.Getenv("ProgramData"), "GoInstaller", application + ".lock")
+}
+
+/*
+    This function takes an exclusive lock without waiting, it
+    returns false when the lock is held by another process.
+    A byte after the end of file is locked, the pid written
+    at the start of the file stays readable.
+*/
+func try_lock(file *os.File) (bool, error) {
+    overlapped := syscall.Overlapped{OffsetHigh: 1}
+    ret, _, err := lockFileEx.Call(file.Fd(), LOCKFILE_EXCLUSIVE_LOCK | LOCKFILE_FAIL_IMMEDIATELY, 0, 1, 0, uintptr(unsafe.Pointer(&overlapped)))
+    if ret != 0 {
+        return true, nil
+    }
+    if err == syscall.Errno(ERROR_LOCK_VIOLATION) {
+        return false, nil
+    }
+    return false, err
+}
+
+/*
+    This function checks if a process is running.
+*/
+func process_alive(pid int) bool {
+    handle, _, _ := openProcess.Call(PROCESS_QUERY_LIMITED_INFORMATION, 0, uintptr(pid))
+    if handle == 0 {
+        return false
+    }
+    defer closeHandle.Call(handle)
+
+    var code uint32
+    ret, _, _ := getExitCodeProcess.Call(handle, uintptr(unsafe.Pointer(&code)))
+    return ret != 0 && code == STILL_ACTIVE
+}
@@ -0,0 +1,267 @@
+/*
+    This file implements install layouts for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "runtime"
+    "os"
+)
+
+type Layout struct {
+    Bin string `json:"bin"`
+    Lib string `json:"lib"`
+    Share string `json:"share"`
+    Config string `json:"config"`
+    Data string `json:"data"`
+    Log string `json:"log"`
+    Service string `json:"service"`
+}
+
+const linux_binaries_directory = "/usr/local/bin"
+const generated_marker = "# Generated by GoInstaller"
+const backup_extension = ".goinstaller-backup"
+
+var categories = []string{"data", "program", "gui", "service", "config"}
+
+var layouts = []string{"", "legacy", "fhs-local", "opt", "custom"}
+
+/*
+    System directories are shared, even when a path
+    element is the application name (like /usr/local/bin
+    for an application named "bin").
+*/
+var system_directories = []string{
+    "/", "/bin", "/etc", "/etc/logrotate.d", "/etc/opt", "/etc/profile.d",
+    "/etc/systemd", "/etc/systemd/journald.conf.d", "/etc/systemd/system", "/opt",
+    "/usr", "/usr/bin", "/usr/lib", "/usr/local", "/usr/local/bin",
+    "/usr/local/lib", "/usr/local/share", "/usr/share", "/var", "/var/lib",
+    "/var/log", "/var/opt",
+}
+
+var layout_directories = []string{"bin", "lib", "share", "config", "data", "log", "service"}
+
+var default_categories = map[string]string{
+    "data": "data",
+    "program": "bin",
+    "gui": "bin",
+    "service": "service",
+    "config": "config",
+}
+
+var default_permissions = map[string]os.FileMode{
+    "config": 0644,
+}
+
+/*
+    This method checks the manifest layout and
+    the layout directories of categories.
+*/
+func (installer *Installer) check_layout() error {
+    if !contains(layouts, installer.manifest.Layout) {
+        return failure(ExitPayload, "unknown install layout: %s", installer.manifest.Layout)
+    }
+
+    for category, role := range installer.manifest.Categories {
+        if !contains(layout_directories, role) {
+            return failure(ExitPayload, "unknown layout directory %q for category %s", role, category)
+        }
+    }
+    return nil
+}
+
+/*
+    This method returns the install layout selected
+    in the manifest:
+
+     - legacy (default): /usr/local/bin/<app> and /var/lib/<app>
+     - fhs-local: /usr/local/{bin,lib,share}, /etc/<app> and /var/lib/<app>
+     - opt: /opt/<app>, /etc/opt/<app> and /var/opt/<app>
+     - custom: manifest "paths", missing paths use the legacy layout
+
+    On Windows only the custom layout overrides default paths.
+    The install location chosen in the wizard replaces
+    the programs directory.
+*/
+func (installer *Installer) get_layout() Layout {
+    return installer.os_layout(runtime.GOOS)
+}
+
+/*
+    This method returns the install layout of an
+    operating system, packages use the Linux layout.
+*/
+func (installer *Installer) os_layout(goos string) Layout {
+    var layout Layout
+    if goos == "windows" {
+        layout = installer.windows_layout()
+    } else {
+        layout = installer.linux_layout(installer.manifest.Layout)
+    }
+
+    if installer.manifest.Layout == "custom" {
+        layout = merge_layout(installer.manifest.Paths, layout)
+    }
+
+    if installer.options.Location != "" {
+        layout.Bin = installer.options.Location
+        if goos == "windows" {
+            layout.Lib = installer.options.Location
+            layout.Share = installer.options.Location
+            layout.Service = installer.options.Location
+        }
+    }
+
+    return layout
+}
+
+/*
+    This method returns the Windows layout.
+*/
+func (installer *Installer) windows_layout() Layout {
+    program_files_dir := filepath.Join(os.Getenv("PROGRAMFILES"), installer.name)
+    program_data_dir := filepath.Join(os.Getenv("PROGRAMDATA"), installer.name)
+    return Layout{
+        Bin: program_files_dir,
+        Lib: program_files_dir,
+        Share: program_files_dir,
+        Config: filepath.Join(program_data_dir, "config"),
+        Data: program_data_dir,
+        Service: program_files_dir,
+    }
+}
+
+/*
+    This method returns a Linux layout by name,
+    names are checked when the manifest is loaded.
+*/
+func (installer *Installer) linux_layout(name string) Layout {
+    switch name {
+    case "fhs-local":
+        return Layout{
+            Bin: "/usr/local/bin",
+            Lib: filepath.Join("/usr/local/lib", installer.name),
+            Share: filepath.Join("/usr/local/share", installer.name),
+            Config: filepath.Join("/etc", installer.name),
+            Data: filepath.Join("/var/lib", installer.name),
+            Log: filepath.Join("/var/log", installer.name),
+            Service: "/etc/systemd/system",
+        }
+    case "opt":
+        return Layout{
+            Bin: filepath.Join("/opt", installer.name, "bin"),
+            Lib: filepath.Join("/opt", installer.name, "lib"),
+            Share: filepath.Join("/opt", installer.name, "share"),
+            Config: filepath.Join("/etc/opt", installer.name),
+            Data: filepath.Join("/var/opt", installer.name),
+            Log: filepath.Join("/var/log", installer.name),
+            Service: "/etc/systemd/system",
+        }
+    }
+
+    return Layout{
+        Bin: filepath.Join("/usr/local/bin", installer.name),
+        Lib: filepath.Join("/usr/local/lib", installer.name),
+        Share: filepath.Join("/usr/local/share", installer.name),
+        Config: filepath.Join("/etc", installer.name),
+        Data: filepath.Join("/var/lib", installer.name),
+        Log: filepath.Join("/var/log", installer.name),
+        Service: "/etc/systemd/system",
+    }
+}
+
+/*
+    This function replaces default paths by non empty custom paths.
+*/
+func merge_layout(custom Layout, defaults Layout) Layout {
+    if custom.Bin != "" {
+        defaults.Bin = custom.Bin
+    }
+    if custom.Lib != "" {
+        defaults.Lib = custom.Lib
+    }
+    if custom.Share != "" {
+        defaults.Share = custom.Share
+    }
+    if custom.Config != "" {
+        defaults.Config = custom.Config
+    }
+    if custom.Data != "" {
+        defaults.Data = custom.Data
+    }
+    if custom.Log != "" {
+        defaults.Log = custom.Log
+    }
+    if custom.Service != "" {
+        defaults.Service = custom.Service
+    }
+    return defaults
+}
+
+/*
+    This method returns the layout directory for
+    a payload category, manifest "categories" can
+    map a category on another layout directory.
+*/
+func (installer *Installer) category_directory(layout Layout, category string) string {
+    role, ok := installer.manifest.Categories[category]
+    if !ok {
+        role = default_categories[category]
+    }
+
+    switch role {
+    case "bin":
+        return layout.Bin
+    case "lib":
+        return layout.Lib
+    case "share":
+        return layout.Share
+    case "config":
+        return layout.Config
+    case "data":
+        return layout.Data
+    case "log":
+        return layout.Log
+    }
+    return layout.Service
+}
+
+/*
+    This function returns the default permissions
+    for files of a payload category.
+*/
+func category_permissions(category string) os.FileMode {
+    permissions, ok := default_permissions[category]
+    if !ok {
+        return 0755
+    }
+    return permissions
+}
+
+/*
+    This method returns the /etc/profile.d script
+    adding the program directory to the PATH.
+*/
+func (installer *Installer) profile_script(program_directory string) string {
+    return generated_marker + " for " + installer.name + ", removed on uninstall.\n" +
+        "case \":$PATH:\" in\n" +
+        "    *\":" + program_directory + ":\"*) ;;\n" +
+        "    *) PATH=\"$PATH:" + program_directory + "\"; export PATH ;;\n" +
+        "esac\n"
+}
@@ -0,0 +1,135 @@
+/*
+    This file implements the license acceptance for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "os/user"
+    "strings"
+    "io/fs"
+    "time"
+    "fmt"
+    "os"
+)
+
+type LicenseAcceptance struct {
+    AcceptedBy string `json:"accepted_by"`
+    AcceptedAt time.Time `json:"accepted_at"`
+    Method string `json:"method"`
+    Hash string `json:"sha256"`
+}
+
+/*
+    This method returns the license text: the manifest
+    "license" or the payload file "license_file".
+*/
+func (installer *Installer) license_text() (string, error) {
+    if installer.manifest.License != "" || installer.manifest.LicenseFile == "" {
+        return installer.manifest.License, nil
+    }
+
+    content, err := fs.ReadFile(installer.payload, installer.manifest.LicenseFile)
+    if err != nil {
+        return "", failure(ExitLicense, "reading license %s: %v", installer.manifest.LicenseFile, err)
+    }
+    return string(content), nil
+}
+
+/*
+    This method checks the manifest "license_file": only
+    category directories are embedded and packed, the license
+    file is a payload file of a category ("config/LICENSE"),
+    it's not installed with other files of the category.
+*/
+func (installer *Installer) validate_license() error {
+    name := installer.manifest.LicenseFile
+    if name == "" {
+        return nil
+    }
+
+    category, file, ok := strings.Cut(name, "/")
+    if !ok || !contains(categories, category) || file == "" || file == "." || file == ".." || strings.ContainsAny(file, `/\`) {
+        return failure(ExitPayload, "invalid license file %q: it must be a file of a category directory (%s)", name, strings.Join(categories, ", "))
+    }
+    return nil
+}
+
+/*
+    This method asks the user to type "yes" to accept the license.
+*/
+func (installer *Installer) ask_license(license string) bool {
+    fmt.Printf("\n--- License ---\n\n%s\n\n", strings.TrimRight(license, "\n"))
+    if strings.ToLower(installer.ask("Type \"yes\" to accept the license", "")) != "yes" {
+        return false
+    }
+
+    installer.accept_license(license, "interactive")
+    return true
+}
+
+/*
+    This method saves who accepted the license and when.
+*/
+func (installer *Installer) accept_license(license string, method string) {
+    installer.license_acceptance = &LicenseAcceptance{
+        AcceptedBy: license_user(),
+        AcceptedAt: time.Now().UTC(),
+        Method: method,
+        Hash: hash_data([]byte(license)),
+    }
+}
+
+/*
+    This function returns the user accepting the license,
+    the user running sudo when the installer is elevated.
+*/
+func license_user() string {
+    name := "unknown"
+    current, err := user.Current()
+    if err == nil {
+        name = current.Username
+    }
+
+    if sudo_user := os.Getenv("SUDO_USER"); sudo_user != "" && sudo_user != name {
+        return sudo_user + " (as " + name + ")"
+    }
+    return name
+}
+
+/*
+    This method requires the license acceptance before the
+    install: with Options.AcceptLicense or by typing "yes"
+    on a terminal, otherwise it returns an error.
+*/
+func (installer *Installer) require_license() error {
+    license, err := installer.license_text()
+    if err != nil || license == "" || installer.license_acceptance != nil {
+        return err
+    }
+
+    if installer.options.AcceptLicense {
+        installer.accept_license(license, "--accept-license")
+        return nil
+    }
+
+    if is_terminal() && installer.ask_license(license) {
+        return nil
+    }
+
+    return failure(ExitLicense, "the license must be accepted to install %s (use --accept-license)", installer.name)
+}
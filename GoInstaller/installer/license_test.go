@@ -0,0 +1,81 @@
+/*
+    This file tests the license acceptance for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "path/filepath"
+    "context"
+    "strings"
+    "testing"
+)
+
+/*
+    This function tests that the license file is not
+    installed, packed or extracted with its category.
+*/
+func TestLicenseFileExcluded(t *testing.T) {
+    payload := fstest.MapFS{"config/LICENSE": test_file("license text"), "config/app.conf": test_file("key=value")}
+    setup := new_test_installer(t, `{"license_file": "config/LICENSE"}`, payload, NewMemoryFileSystem())
+    setup.options.AcceptLicense = true
+
+    tests := []struct {
+        name string
+        files func() []string
+    }{
+        {name: "install", files: func() []string {
+            err := setup.Install(context.Background())
+            if err != nil {
+                t.Fatalf("Install: %v", err)
+            }
+
+            var files []string
+            for _, file := range setup.receipt.Files {
+                files = append(files, filepath.ToSlash(file.Path))
+            }
+            return files
+        }},
+        {name: "package", files: func() []string {
+            var files []string
+            for _, file := range setup.package_files() {
+                files = append(files, file.path)
+            }
+            return files
+        }},
+        {name: "portable", files: func() []string {
+            var files []string
+            for _, file := range setup.portable_files() {
+                files = append(files, file.path)
+            }
+            return files
+        }},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            files := strings.Join(test.files(), "\n")
+            if strings.Contains(files, "LICENSE") {
+                t.Errorf("license file in %s files:\n%s", test.name, files)
+            }
+            if !strings.Contains(files, "app.conf") {
+                t.Errorf("config file not in %s files:\n%s", test.name, files)
+            }
+        })
+    }
+}
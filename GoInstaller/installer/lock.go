@@ -0,0 +1,162 @@
+/*
+    This file implements the install lock for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "context"
+    "strings"
+    "sync"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+/*
+    Default time to wait for another installation.
+*/
+const default_lock_timeout = time.Minute
+
+/*
+    An exclusive lock of the application installs, the
+    lock is released by the system when the process dies.
+*/
+type install_lock struct {
+    file *os.File
+    once sync.Once
+}
+
+/*
+    This method takes the application install lock, it
+    waits for another installation until the lock timeout
+    (Options.LockTimeout, a negative timeout doesn't wait).
+    The lock file (Options.LockFile, default is the system
+    lock path) contains the pid of the installer, a pid
+    of a dead process is a stale lock and is replaced.
+    Nothing is locked when the target is not the OS
+    filesystem.
+*/
+func (installer *Installer) lock(ctx context.Context) (*install_lock, error) {
+    if _, ok := installer.target.(OSFileSystem); !ok {
+        return &install_lock{}, nil
+    }
+
+    path := installer.options.LockFile
+    if path == "" {
+        path = lock_path(installer.name)
+    }
+    err := os.MkdirAll(filepath.Dir(path), 0755)
+    if err != nil {
+        return nil, failure(ExitLock, "creating lock directory: %v", err)
+    }
+
+    file, err := os.OpenFile(path, os.O_RDWR | os.O_CREATE, 0644)
+    if err != nil {
+        return nil, failure(ExitLock, "opening lock %s: %v", path, err)
+    }
+
+    timeout := installer.options.LockTimeout
+    if timeout == 0 {
+        timeout = default_lock_timeout
+    }
+    deadline := time.Now().Add(timeout)
+
+    waiting := false
+    for {
+        locked, err := try_lock(file)
+        if err != nil {
+            file.Close()
+            return nil, failure(ExitLock, "locking %s: %v", path, err)
+        }
+
+        owner := lock_owner(file)
+        if locked {
+            if owner != 0 && owner != os.Getpid() && !process_alive(owner) {
+                fmt.Fprintf(os.Stderr, "Stale install lock of pid %d replaced: %s\n", owner, path)
+            }
+            err = write_lock_owner(file)
+            if err != nil {
+                file.Close()
+                return nil, err
+            }
+            return &install_lock{file: file}, nil
+        }
+
+        if time.Now().After(deadline) || timeout < 0 {
+            file.Close()
+            return nil, failure(ExitLock, "another installation is in progress (pid %d), lock: %s", owner, path)
+        }
+
+        if !waiting {
+            waiting = true
+            fmt.Fprintf(os.Stderr, "Waiting for another installation in progress (pid %d)...\n", owner)
+        }
+
+        select {
+        case <-time.After(500 * time.Millisecond):
+        case <-ctx.Done():
+            file.Close()
+            return nil, ctx.Err()
+        }
+    }
+}
+
+/*
+    This method releases the install lock, the lock file
+    is emptied but not removed: a process waiting for the
+    lock keeps the same file. The lock is released once,
+    next calls do nothing.
+*/
+func (lock *install_lock) release() {
+    lock.once.Do(func() {
+        if lock.file != nil {
+            lock.file.Truncate(0)
+            lock.file.Close()
+        }
+    })
+}
+
+/*
+    This function returns the pid written in the lock file.
+*/
+func lock_owner(file *os.File) int {
+    content, err := io.ReadAll(io.NewSectionReader(file, 0, 32))
+    if err != nil {
+        return 0
+    }
+
+    var pid int
+    fmt.Sscan(strings.TrimSpace(string(content)), &pid)
+    return pid
+}
+
+/*
+    This function writes the installer pid in the lock file.
+*/
+func write_lock_owner(file *os.File) error {
+    err := file.Truncate(0)
+    if err == nil {
+        _, err = file.WriteAt([]byte(fmt.Sprintf("%d\n", os.Getpid())), 0)
+    }
+    if err != nil {
+        return failure(ExitLock, "writing lock owner: %v", err)
+    }
+    return nil
+}
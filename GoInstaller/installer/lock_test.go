@@ -0,0 +1,117 @@
+/*
+    This file tests the install lock for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "os/exec"
+    "context"
+    "testing"
+    "errors"
+    "time"
+    "fmt"
+    "os"
+)
+
+/*
+    This function returns the pid of an exited process.
+*/
+func dead_pid(t *testing.T) int {
+    t.Helper()
+    command := exec.Command(os.Args[0], "-test.run=^$")
+    err := command.Run()
+    if err != nil {
+        t.Fatalf("running a test process: %v", err)
+    }
+    return command.Process.Pid
+}
+
+/*
+    This function tests the install lock: contention with
+    and without waiting, the takeover of a lock written by
+    a dead process and the in-memory target without lock.
+*/
+func TestInstallLock(t *testing.T) {
+    tests := []struct {
+        name string
+        held bool
+        release time.Duration
+        timeout time.Duration
+        owner bool
+        memory bool
+        fails bool
+    }{
+        {name: "free lock", timeout: -1},
+        {name: "lock contention", held: true, timeout: -1, fails: true},
+        {name: "lock contention timeout", held: true, timeout: 600 * time.Millisecond, fails: true},
+        {name: "lock released while waiting", held: true, release: 200 * time.Millisecond, timeout: 5 * time.Second},
+        {name: "dead pid takeover", owner: true, timeout: -1},
+        {name: "in-memory target", held: true, memory: true, timeout: -1},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            path := filepath.Join(t.TempDir(), "testapp.lock")
+            options := Options{LockFile: path, LockTimeout: test.timeout}
+
+            if test.owner {
+                err := os.WriteFile(path, []byte(fmt.Sprintf("%d\n", dead_pid(t))), 0644)
+                if err != nil {
+                    t.Fatal(err)
+                }
+            }
+
+            if test.held {
+                holder := &Installer{name: "testapp", target: OSFileSystem{}, options: Options{LockFile: path, LockTimeout: -1}}
+                lock, err := holder.lock(context.Background())
+                if err != nil {
+                    t.Fatalf("first lock: %v", err)
+                }
+                if test.release > 0 {
+                    time.AfterFunc(test.release, lock.release)
+                } else {
+                    defer lock.release()
+                }
+            }
+
+            var target FileSystem = OSFileSystem{}
+            if test.memory {
+                target = NewMemoryFileSystem()
+            }
+            setup := &Installer{name: "testapp", target: target, options: options}
+            lock, err := setup.lock(context.Background())
+
+            var installer_error *Error
+            if test.fails {
+                if !errors.As(err, &installer_error) || installer_error.Code != ExitLock {
+                    t.Fatalf("lock: %v, expected a lock error", err)
+                }
+                return
+            }
+            if err != nil {
+                t.Fatalf("lock: %v", err)
+            }
+            defer lock.release()
+
+            if !test.memory && lock_owner(lock.file) != os.Getpid() {
+                t.Errorf("lock owner is %d, expected %d", lock_owner(lock.file), os.Getpid())
+            }
+        })
+    }
+}
@@ -0,0 +1,75 @@
+/*
+    This file implements log rotation settings for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "strings"
+    "regexp"
+    "path"
+)
+
+var rotation_days = map[string]int{
+    "": 7,
+    "daily": 1,
+    "weekly": 7,
+    "monthly": 31,
+    "yearly": 366,
+}
+
+var rotation_size = regexp.MustCompile(`^[0-9]+[kKmMgG]?$`)
+
+/*
+    This method checks the manifest "logrotate" settings: a
+    known frequency, a positive number of rotations, a size
+    in bytes (k, M or G suffix) and a file name pattern.
+*/
+func (installer *Installer) validate_log_rotation() error {
+    settings := installer.manifest.LogRotate
+    if settings == nil {
+        return nil
+    }
+
+    if _, ok := rotation_days[settings.Frequency]; !ok {
+        return failure(ExitPayload, "invalid log rotation frequency: %q (daily, weekly, monthly, yearly)", settings.Frequency)
+    }
+
+    if settings.Rotate < 0 {
+        return failure(ExitPayload, "invalid log rotation count: %d", settings.Rotate)
+    }
+
+    if settings.MaxSize != "" && !rotation_size.MatchString(settings.MaxSize) {
+        return failure(ExitPayload, "invalid log rotation max_size: %q (bytes with optional k, M or G suffix)", settings.MaxSize)
+    }
+
+    if strings.ContainsAny(settings.Pattern, "/\\\n") || settings.Pattern == "." || settings.Pattern == ".." {
+        return failure(ExitPayload, "invalid log rotation pattern: %q", settings.Pattern)
+    }
+    return nil
+}
+
+/*
+    This method returns the file configuring the log
+    rotation backend ("logrotate" or "journald").
+*/
+func (installer *Installer) log_rotation_file(backend string) string {
+    if backend == "journald" {
+        return path.Join("/etc/systemd/journald.conf.d", installer.name + ".conf")
+    }
+    return path.Join("/etc/logrotate.d", installer.name)
+}
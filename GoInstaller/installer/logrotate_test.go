@@ -0,0 +1,133 @@
+/*
+    This file tests log rotation settings for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "context"
+    "runtime"
+    "strings"
+    "testing"
+    "errors"
+    "path"
+)
+
+/*
+    This function tests that invalid log rotation settings
+    fail when the manifest is loaded.
+*/
+func TestValidateLogRotation(t *testing.T) {
+    tests := []struct {
+        name string
+        settings string
+        fails bool
+    }{
+        {name: "defaults", settings: `{}`},
+        {name: "valid settings", settings: `{"frequency": "daily", "rotate": 7, "max_size": "100M", "pattern": "*.log"}`},
+        {name: "unknown frequency", settings: `{"frequency": "hourly"}`, fails: true},
+        {name: "negative rotate", settings: `{"rotate": -1}`, fails: true},
+        {name: "invalid size", settings: `{"max_size": "100 MB"}`, fails: true},
+        {name: "pattern directory", settings: `{"pattern": "../*.log"}`, fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            payload := fstest.MapFS{"manifest.json": test_file(`{"logrotate": ` + test.settings + `}`)}
+            _, err := New(Options{Name: "testapp", Payload: payload, FileSystem: NewMemoryFileSystem()})
+
+            var installer_error *Error
+            if test.fails && (!errors.As(err, &installer_error) || installer_error.Code != ExitPayload) {
+                t.Fatalf("New: %v, expected a payload error", err)
+            }
+            if !test.fails && err != nil {
+                t.Fatalf("New: %v", err)
+            }
+        })
+    }
+}
+
+/*
+    This function tests that the plan shows the log rotation
+    backend of the target and the file written by the install.
+*/
+func TestPlanLogRotation(t *testing.T) {
+    if runtime.GOOS == "windows" {
+        t.Skip("logs are not rotated on Windows")
+    }
+
+    tests := []struct {
+        name string
+        host string
+        backend string
+        content string
+    }{
+        {name: "logrotate", host: "/usr/sbin/logrotate", backend: "logrotate", content: "maxsize 10m"},
+        {name: "journald", host: "/run/systemd/system/", backend: "journald", content: "SystemMaxFileSize=10M"},
+        {name: "no backend", host: "/tmp/"},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            target := NewMemoryFileSystem()
+            target.MkdirAll(path.Dir(test.host), 0755)
+            if !strings.HasSuffix(test.host, "/") {
+                write_target(target, test.host, []byte("binary"), 0755)
+            }
+
+            payload := fstest.MapFS{"program/app": test_file("binary")}
+            setup := new_test_installer(t, `{"logrotate": {"frequency": "daily", "rotate": 3, "max_size": "10m"}}`, payload, target)
+            plan, err := setup.Plan(context.Background())
+            if err != nil {
+                t.Fatalf("Plan: %v", err)
+            }
+
+            var planned string
+            for _, action := range plan.Actions {
+                if action.Kind == "logrotate" {
+                    planned = action.Path
+                    if action.Source != test.backend {
+                        t.Errorf("planned backend %q, expected %q", action.Source, test.backend)
+                    }
+                }
+            }
+
+            err = setup.Install(context.Background())
+            if err != nil {
+                t.Fatalf("Install: %v", err)
+            }
+
+            var applied string
+            for _, file := range setup.receipt.Files {
+                if file.Category == "logrotate" {
+                    applied = file.Path
+                }
+            }
+            if planned != applied {
+                t.Fatalf("planned log rotation %q, applied %q", planned, applied)
+            }
+
+            if test.backend != "" {
+                content, err := read_target(target, applied)
+                if err != nil || !strings.Contains(string(content), test.content) {
+                    t.Errorf("log rotation file %s: %q %v, expected %q", applied, content, err, test.content)
+                }
+            }
+        })
+    }
+}
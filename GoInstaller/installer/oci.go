@@ -0,0 +1,280 @@
+/*
+    This file implements OCI images export for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// installer export layer application.tar.gz
+// installer export oci application-image
+// skopeo copy oci:application-image:1.0.0 docker-daemon:application:1.0.0
+
+package installer
+
+import (
+    "compress/gzip"
+    "encoding/json"
+    "encoding/hex"
+    "crypto/sha256"
+    "path/filepath"
+    "runtime"
+    "strings"
+    "path"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+const oci_manifest_type = "application/vnd.oci.image.manifest.v1+json"
+const oci_config_type = "application/vnd.oci.image.config.v1+json"
+const oci_layer_type = "application/vnd.oci.image.layer.v1.tar+gzip"
+const oci_index_type = "application/vnd.oci.image.index.v1+json"
+
+/*
+    An image runs on one architecture: an "all"
+    package uses the build host architecture.
+*/
+var oci_architectures = map[string]string{
+    "all": runtime.GOARCH,
+}
+
+type OciDescriptor struct {
+    MediaType string `json:"mediaType"`
+    Digest string `json:"digest"`
+    Size int64 `json:"size"`
+    Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type OciImageConfig struct {
+    Created time.Time `json:"created"`
+    Architecture string `json:"architecture"`
+    OS string `json:"os"`
+    Config OciRuntimeConfig `json:"config"`
+    RootFS OciRootFS `json:"rootfs"`
+    History []OciHistory `json:"history"`
+}
+
+type OciRuntimeConfig struct {
+    Entrypoint []string `json:"Entrypoint,omitempty"`
+    Env []string `json:"Env"`
+    Labels map[string]string `json:"Labels,omitempty"`
+}
+
+type OciRootFS struct {
+    Type string `json:"type"`
+    DiffIDs []string `json:"diff_ids"`
+}
+
+type OciHistory struct {
+    Created time.Time `json:"created"`
+    CreatedBy string `json:"created_by"`
+}
+
+/*
+    This method writes the files of a Linux install in an
+    OCI layer (a .tar.gz archive owned by root), commands
+    are not run and remote files are not packaged. It
+    returns the layer descriptor and its uncompressed digest.
+*/
+func (installer *Installer) write_layer(output string) (OciDescriptor, string, error) {
+    files := installer.package_files()
+    for _, command := range installer.install_commands("linux") {
+        fmt.Fprintf(os.Stderr, "Command not run in the image: %s\n", command)
+    }
+
+    file, err := os.Create(output)
+    if err != nil {
+        return OciDescriptor{}, "", err
+    }
+    defer file.Close()
+
+    hasher := sha256.New()
+    counter := &counting_writer{}
+    _, _, err = write_deb_data(io.MultiWriter(file, hasher, counter), files, package_time())
+    if err == nil {
+        err = file.Close()
+    }
+    if err != nil {
+        return OciDescriptor{}, "", err
+    }
+
+    diff_id, err := layer_diff_id(output)
+    descriptor := OciDescriptor{
+        MediaType: oci_layer_type,
+        Digest: "sha256:" + hex.EncodeToString(hasher.Sum(nil)),
+        Size: counter.size,
+    }
+    return descriptor, diff_id, err
+}
+
+/*
+    This method writes an OCI layer tarball.
+*/
+func (installer *Installer) write_layer_tarball(output string) error {
+    _, _, err := installer.write_layer(output)
+    return err
+}
+
+/*
+    This function returns the digest of the uncompressed layer.
+*/
+func layer_diff_id(path string) (string, error) {
+    file, err := os.Open(path)
+    if err != nil {
+        return "", err
+    }
+    defer file.Close()
+
+    decompressor, err := gzip.NewReader(file)
+    if err != nil {
+        return "", err
+    }
+
+    hash, err := hash_reader(decompressor)
+    return "sha256:" + hash, err
+}
+
+/*
+    This method writes an OCI image layout directory (oci-layout,
+    index.json and blobs) with one layer, the image entrypoint
+    is the manifest "entrypoint" program and its arguments.
+    The image is tagged with the package version.
+*/
+func (installer *Installer) write_oci_image(output string) error {
+    entrypoint, err := installer.image_entrypoint()
+    if err != nil {
+        return err
+    }
+
+    blobs := filepath.Join(output, "blobs", "sha256")
+    err = os.MkdirAll(blobs, 0755)
+    if err != nil {
+        return err
+    }
+
+    temporary := filepath.Join(blobs, ".layer.tar.gz")
+    layer, diff_id, err := installer.write_layer(temporary)
+    if err == nil {
+        err = os.Rename(temporary, filepath.Join(blobs, strings.TrimPrefix(layer.Digest, "sha256:")))
+    }
+    if err != nil {
+        os.Remove(temporary)
+        return err
+    }
+
+    created := package_time()
+    image := OciImageConfig{
+        Created: created,
+        Architecture: installer.package_architecture(oci_architectures),
+        OS: "linux",
+        Config: OciRuntimeConfig{
+            Entrypoint: entrypoint,
+            Env: []string{"PATH=" + installer.image_path()},
+            Labels: map[string]string{
+                "org.opencontainers.image.title": installer.package_name(),
+                "org.opencontainers.image.version": installer.package_version(),
+                "org.opencontainers.image.description": installer.package_description(),
+            },
+        },
+        RootFS: OciRootFS{Type: "layers", DiffIDs: []string{diff_id}},
+        History: []OciHistory{{Created: created, CreatedBy: "GoInstaller export oci"}},
+    }
+
+    config, err := write_blob(blobs, oci_config_type, image)
+    if err != nil {
+        return err
+    }
+
+    manifest, err := write_blob(blobs, oci_manifest_type, map[string]any{
+        "schemaVersion": 2,
+        "mediaType": oci_manifest_type,
+        "config": config,
+        "layers": []OciDescriptor{layer},
+    })
+    if err != nil {
+        return err
+    }
+
+    manifest.Annotations = map[string]string{"org.opencontainers.image.ref.name": installer.package_version()}
+    index, err := json.MarshalIndent(map[string]any{
+        "schemaVersion": 2,
+        "mediaType": oci_index_type,
+        "manifests": []OciDescriptor{manifest},
+    }, "", "    ")
+    if err == nil {
+        err = os.WriteFile(filepath.Join(output, "index.json"), index, 0644)
+    }
+    if err == nil {
+        err = os.WriteFile(filepath.Join(output, "oci-layout"), []byte(`{"imageLayoutVersion": "1.0.0"}`), 0644)
+    }
+    return err
+}
+
+/*
+    This function writes a JSON blob named by its
+    digest and returns its descriptor.
+*/
+func write_blob(blobs string, media_type string, value any) (OciDescriptor, error) {
+    content, err := json.Marshal(value)
+    if err != nil {
+        return OciDescriptor{}, err
+    }
+
+    hash := hash_data(content)
+    err = os.WriteFile(filepath.Join(blobs, hash), content, 0644)
+    return OciDescriptor{MediaType: media_type, Digest: "sha256:" + hash, Size: int64(len(content))}, err
+}
+
+/*
+    This method returns the image entrypoint: the manifest
+    "entrypoint" program (a program file name) in the
+    programs directory and its arguments with variables.
+*/
+func (installer *Installer) image_entrypoint() ([]string, error) {
+    if len(installer.manifest.Entrypoint) == 0 {
+        return nil, nil
+    }
+
+    program := installer.manifest.Entrypoint[0]
+    found := false
+    for _, file := range installer.package_files() {
+        if file.category == "program" && path.Base(file.path) == program {
+            found = true
+        }
+    }
+    if !found {
+        return nil, fmt.Errorf("entrypoint %s is not an installed program", program)
+    }
+
+    directory := filepath.ToSlash(installer.category_directory(installer.os_layout("linux"), "program"))
+    entrypoint := []string{path.Join(directory, program)}
+    for _, argument := range installer.manifest.Entrypoint[1:] {
+        entrypoint = append(entrypoint, installer.expand_variables(argument))
+    }
+    return entrypoint, nil
+}
+
+/*
+    This method returns the image PATH with
+    the programs directory.
+*/
+func (installer *Installer) image_path() string {
+    image_path := "/usr/local/sbin:/usr/local/bin:/usr/sbin:/usr/bin:/sbin:/bin"
+    programs := filepath.ToSlash(installer.os_layout("linux").Bin)
+    if programs == linux_binaries_directory {
+        return image_path
+    }
+    return programs + ":" + image_path
+}
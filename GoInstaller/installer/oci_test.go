@@ -0,0 +1,147 @@
+/*
+    This file tests OCI images for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "encoding/json"
+    "path/filepath"
+    "compress/gzip"
+    "strings"
+    "context"
+    "runtime"
+    "testing"
+    "bytes"
+    "path"
+    "os"
+)
+
+/*
+    This function reads a blob of an image layout, the
+    content must match the descriptor digest and size.
+*/
+func read_oci_blob(t *testing.T, output string, descriptor OciDescriptor) []byte {
+    t.Helper()
+    content, err := os.ReadFile(filepath.Join(output, "blobs", "sha256", strings.TrimPrefix(descriptor.Digest, "sha256:")))
+    if err != nil {
+        t.Fatalf("reading blob %s: %v", descriptor.Digest, err)
+    }
+    if "sha256:" + hash_data(content) != descriptor.Digest || int64(len(content)) != descriptor.Size {
+        t.Fatalf("blob %s doesn't match its descriptor", descriptor.Digest)
+    }
+    return content
+}
+
+/*
+    This function tests the OCI image layout: oci-layout,
+    index.json and blobs addressed by their digest, the
+    image config (architecture, entrypoint and layer
+    diff_id) and the files of the layer.
+*/
+func TestWriteOciImage(t *testing.T) {
+    tests := []struct {
+        name string
+        manifest string
+        architecture string
+        entrypoint []string
+        fails bool
+    }{
+        {name: "build architecture", manifest: `{}`, architecture: runtime.GOARCH},
+        {name: "architecture independent", manifest: `{"architecture": "all"}`, architecture: runtime.GOARCH},
+        {name: "manifest architecture", manifest: `{"architecture": "arm64"}`, architecture: "arm64"},
+        {name: "entrypoint", manifest: `{"entrypoint": ["app", "--serve"]}`, architecture: runtime.GOARCH, entrypoint: []string{"app", "--serve"}},
+        {name: "unknown entrypoint", manifest: `{"entrypoint": ["missing"]}`, fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            payload := fstest.MapFS{"program/app": test_file("binary")}
+            setup := new_test_installer(t, test.manifest, payload, NewMemoryFileSystem())
+            output := filepath.Join(t.TempDir(), "image")
+            err := setup.Export(context.Background(), "oci", output)
+            if test.fails != (err != nil) {
+                t.Fatalf("Export: %v, expected failure: %t", err, test.fails)
+            }
+            if test.fails {
+                return
+            }
+
+            layout, err := os.ReadFile(filepath.Join(output, "oci-layout"))
+            if err != nil || !strings.Contains(string(layout), `"imageLayoutVersion": "1.0.0"`) {
+                t.Errorf("invalid oci-layout: %q %v", layout, err)
+            }
+
+            var index struct {
+                SchemaVersion int `json:"schemaVersion"`
+                Manifests []OciDescriptor `json:"manifests"`
+            }
+            content, err := os.ReadFile(filepath.Join(output, "index.json"))
+            if err == nil {
+                err = json.Unmarshal(content, &index)
+            }
+            if err != nil || index.SchemaVersion != 2 || len(index.Manifests) != 1 || index.Manifests[0].MediaType != oci_manifest_type {
+                t.Fatalf("invalid index.json: %s %v", content, err)
+            }
+            if index.Manifests[0].Annotations["org.opencontainers.image.ref.name"] != setup.package_version() {
+                t.Errorf("the image is not tagged with the version: %v", index.Manifests[0].Annotations)
+            }
+
+            var manifest struct {
+                Config OciDescriptor `json:"config"`
+                Layers []OciDescriptor `json:"layers"`
+            }
+            err = json.Unmarshal(read_oci_blob(t, output, index.Manifests[0]), &manifest)
+            if err != nil || manifest.Config.MediaType != oci_config_type || len(manifest.Layers) != 1 || manifest.Layers[0].MediaType != oci_layer_type {
+                t.Fatalf("invalid image manifest: %v", err)
+            }
+
+            var config OciImageConfig
+            err = json.Unmarshal(read_oci_blob(t, output, manifest.Config), &config)
+            if err != nil {
+                t.Fatalf("invalid image config: %v", err)
+            }
+            if config.Architecture != test.architecture || config.OS != "linux" {
+                t.Errorf("image platform %s/%s, expected linux/%s", config.OS, config.Architecture, test.architecture)
+            }
+
+            layer := read_oci_blob(t, output, manifest.Layers[0])
+            decompressor, err := gzip.NewReader(bytes.NewReader(layer))
+            if err != nil {
+                t.Fatalf("invalid layer: %v", err)
+            }
+            diff_id, err := hash_reader(decompressor)
+            if err != nil || len(config.RootFS.DiffIDs) != 1 || config.RootFS.DiffIDs[0] != "sha256:" + diff_id {
+                t.Errorf("diff_ids %v, expected sha256:%s", config.RootFS.DiffIDs, diff_id)
+            }
+
+            program := path.Join(filepath.ToSlash(setup.category_directory(setup.os_layout("linux"), "program")), "app")
+            if read_tar_gz(t, layer)["." + program] != "binary" {
+                t.Errorf("%s is not in the layer", program)
+            }
+
+            var entrypoint []string
+            if len(test.entrypoint) > 0 {
+                entrypoint = append([]string{program}, test.entrypoint[1:]...)
+            }
+            if strings.Join(config.Config.Entrypoint, " ") != strings.Join(entrypoint, " ") {
+                t.Errorf("entrypoint %v, expected %v", config.Config.Entrypoint, entrypoint)
+            }
+        })
+    }
+}
@@ -0,0 +1,217 @@
+/*
+    This file implements the install plan for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "runtime"
+    "context"
+)
+
+type Plan struct {
+    Layout Layout
+    Components []string
+    Actions []Action
+}
+
+/*
+    An install action of a step: "directory", "stop",
+    "file", "download", "remove", "logrotate", "link",
+    "profile", "command", "restart", "service", "check"
+    or "receipt".
+*/
+type Action struct {
+    Step string
+    Kind string
+    Path string
+    Source string
+}
+
+/*
+    This method returns actions of install steps without
+    side effects: privileges are not required, nothing
+    is written and the wizard is not run (choices are
+    defaults of the manifest, options and answer file).
+*/
+func (installer *Installer) Plan(ctx context.Context) (*Plan, error) {
+    previous, _ := installer.load_receipt(receipt_path(installer.get_layout().Data))
+    err := installer.prepare(previous)
+    if err == nil {
+        err = installer.check_service_start()
+    }
+    if err != nil {
+        return nil, err
+    }
+
+    plan := &Plan{Layout: installer.get_layout(), Components: installer.installed_components()}
+    for _, step := range installer.steps {
+        if step.Plan == nil {
+            continue
+        }
+
+        actions, err := step.Plan(ctx, installer)
+        if err == nil {
+            err = ctx.Err()
+        }
+        if err != nil {
+            return nil, err
+        }
+
+        for _, action := range actions {
+            action.Step = step.Name
+            plan.Actions = append(plan.Actions, action)
+        }
+    }
+    return plan, nil
+}
+
+/*
+    This method returns missing software directories.
+*/
+func (installer *Installer) plan_directories(ctx context.Context) ([]Action, error) {
+    layout := installer.get_layout()
+    directories := []string{layout.Data}
+    for _, category := range categories {
+        directories = append(directories, installer.category_directory(layout, category))
+    }
+    if runtime.GOOS != "windows" {
+        directories = append(directories, layout.Log)
+    }
+
+    var actions []Action
+    seen := make(map[string]bool)
+    for _, directory := range directories {
+        for parent := directory; !seen[parent] && !installer.file_exists(parent); parent = filepath.Dir(parent) {
+            seen[parent] = true
+            actions = append(actions, Action{Kind: "directory", Path: parent})
+            if parent == filepath.Dir(parent) {
+                break
+            }
+        }
+    }
+    return actions, nil
+}
+
+/*
+    This method returns payload files to write (existing
+    data files are kept) and files of unselected components.
+*/
+func (installer *Installer) plan_files(ctx context.Context) ([]Action, error) {
+    var actions []Action
+    installed := make(map[string]bool)
+    for _, file := range installer.payload_files(installer.get_layout()) {
+        destination := filepath.Join(file.path, file.name)
+        installed[destination] = true
+        if file.filetype == "data" && installer.file_exists(destination) {
+            continue
+        }
+
+        if file.remote != nil {
+            actions = append(actions, Action{Kind: "download", Path: destination, Source: file.remote.URL})
+        } else {
+            actions = append(actions, Action{Kind: "file", Path: destination, Source: file.filetype + "/" + file.name})
+        }
+    }
+
+    for _, entry := range installer.unselected_files() {
+        if !installed[entry.Path] {
+            actions = append(actions, Action{Kind: "remove", Path: entry.Path, Source: entry.Component})
+        }
+    }
+    return actions, nil
+}
+
+/*
+    This method returns the log rotation of the log directory,
+    the source is the backend ("logrotate" or "journald").
+*/
+func (installer *Installer) plan_log_rotation(ctx context.Context) ([]Action, error) {
+    backend := installer.log_rotation_backend()
+    if backend == "" {
+        return nil, nil
+    }
+    return []Action{{Kind: "logrotate", Path: installer.log_rotation_file(backend), Source: backend}}, nil
+}
+
+/*
+    This method returns actions adding programs to the PATH.
+*/
+func (installer *Installer) plan_path(ctx context.Context) ([]Action, error) {
+    program_directory := installer.get_layout().Bin
+    if installer.windows_system() {
+        return []Action{{Kind: "profile", Path: program_directory, Source: "Path"}}, nil
+    } else if runtime.GOOS == "windows" {
+        return nil, nil
+    }
+
+    if filepath.Clean(program_directory) == linux_binaries_directory {
+        return nil, nil
+    }
+
+    if installer.manifest.PathMode == "profile" {
+        return []Action{{Kind: "profile", Path: filepath.Join("/etc/profile.d", installer.name + ".sh"), Source: program_directory}}, nil
+    }
+
+    var actions []Action
+    for _, command := range installer.manifest.Commands {
+        actions = append(actions, Action{Kind: "link", Path: filepath.Join(linux_binaries_directory, command), Source: filepath.Join(program_directory, command)})
+    }
+    return actions, nil
+}
+
+/*
+    This method returns commands to run.
+*/
+func (installer *Installer) plan_commands(ctx context.Context) ([]Action, error) {
+    var actions []Action
+    for _, command := range installer.install_commands(runtime.GOOS) {
+        actions = append(actions, Action{Kind: "command", Source: command})
+    }
+    return actions, nil
+}
+
+/*
+    This method returns services to enable or start, on
+    Windows services are created with the files step (an
+    empty policy starts services). On Linux, services are
+    not enabled without policy.
+*/
+func (installer *Installer) plan_services(ctx context.Context) ([]Action, error) {
+    policy := installer.options.ServiceStart
+    if runtime.GOOS == "windows" && policy == "" {
+        policy = "start"
+    }
+    if policy == "" || policy == "none" || (runtime.GOOS == "windows" && !installer.windows_system()) {
+        return nil, nil
+    }
+
+    var actions []Action
+    for _, file := range installer.payload_files(installer.get_layout()) {
+        if file.filetype != "service" {
+            continue
+        }
+
+        if runtime.GOOS == "windows" {
+            actions = append(actions, Action{Kind: "service", Path: filepath.Join(file.path, file.name), Source: policy})
+        } else if systemd_unit(file.name) {
+            actions = append(actions, Action{Kind: "service", Path: file.name, Source: policy})
+        }
+    }
+    return actions, nil
+}
@@ -0,0 +1,78 @@
+/*
+    This file tests install plans for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "testing/fstest"
+    "reflect"
+    "context"
+    "strings"
+    "runtime"
+    "testing"
+    "fmt"
+)
+
+/*
+    This function tests that services of the plan are the
+    services enabled by the install, for every services
+    start policy.
+*/
+func TestPlanServices(t *testing.T) {
+    for _, policy := range []string{"", "start", "enable", "none"} {
+        t.Run("policy " + policy, func(t *testing.T) {
+            payload := fstest.MapFS{"service/app.service": test_file("[Service]"), "program/app": test_file("binary")}
+
+            planner := new_test_installer(t, "", payload, NewMemoryFileSystem())
+            planner.options.ServiceStart = policy
+            plan, err := planner.Plan(context.Background())
+            if err != nil {
+                t.Fatalf("Plan: %v", err)
+            }
+
+            var planned []string
+            for _, action := range plan.Actions {
+                if action.Kind == "service" {
+                    planned = append(planned, fmt.Sprintf("enable %s %t", action.Path, action.Source == "start"))
+                }
+            }
+
+            setup := new_test_installer(t, "", payload, NewMemoryFileSystem())
+            setup.options.ServiceStart = policy
+            manager := &recording_service_manager{target: setup.target}
+            setup.services = manager
+            err = setup.Install(context.Background())
+            if err != nil {
+                t.Fatalf("Install: %v", err)
+            }
+
+            var applied []string
+            for _, call := range manager.calls {
+                if strings.HasPrefix(call, "enable ") {
+                    applied = append(applied, call)
+                }
+            }
+            if runtime.GOOS != "windows" && (policy == "start" || policy == "enable") && len(applied) == 0 {
+                t.Errorf("no service enabled with the %s policy", policy)
+            }
+            if !reflect.DeepEqual(planned, applied) {
+                t.Errorf("planned services %q, applied %q", planned, applied)
+            }
+        })
+    }
+}
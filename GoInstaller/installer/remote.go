@@ -0,0 +1,233 @@
+/*
+    This file implements remote payload files for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "net/http"
+    "context"
+    "strings"
+    "errors"
+    "time"
+    "fmt"
+    "io"
+    "os"
+)
+
+type RemoteFile struct {
+    Category string `json:"category"`
+    Name string `json:"name"`
+    URL string `json:"url"`
+    Size int64 `json:"size"`
+    Hash string `json:"sha256"`
+}
+
+const download_retries = 5
+
+/*
+    HTTP client for remote files, proxies are
+    configured with HTTP_PROXY, HTTPS_PROXY
+    and NO_PROXY environment variables.
+*/
+var http_client = &http.Client{
+    Transport: &http.Transport{
+        Proxy: http.ProxyFromEnvironment,
+        ResponseHeaderTimeout: 60 * time.Second,
+        IdleConnTimeout: 90 * time.Second,
+    },
+}
+
+/*
+    This method checks remote files of the manifest: the
+    category is a payload category and the name is a single
+    file name (no directory, no ".."). SHA256 are saved in
+    lower case.
+*/
+func (installer *Installer) validate_remote() error {
+    for index := range installer.manifest.Remote {
+        remote := &installer.manifest.Remote[index]
+        if !contains(categories, remote.Category) {
+            return failure(ExitPayload, "invalid category for remote file %s: %q (%s)", remote.Name, remote.Category, strings.Join(categories, ", "))
+        }
+
+        if remote.Name == "" || remote.Name == "." || remote.Name == ".." || strings.ContainsAny(remote.Name, `/\`) || filepath.Base(remote.Name) != remote.Name {
+            return failure(ExitPayload, "invalid name for remote file: %q", remote.Name)
+        }
+        remote.Hash = strings.ToLower(remote.Hash)
+    }
+    return nil
+}
+
+/*
+    This method returns remote files of the manifest
+    to download them with embedded files.
+*/
+func (installer *Installer) remote_files(layout Layout) []File {
+    var files []File
+    for index := range installer.manifest.Remote {
+        remote := &installer.manifest.Remote[index]
+        file := File{
+            filetype: remote.Category,
+            path: installer.category_directory(layout, remote.Category),
+            name: remote.Name,
+            remote: remote,
+        }
+
+        if installer.windows_system() && remote.Category == "gui" {
+            file.callback = installer.add_to_windows_menu
+        } else if installer.windows_system() && remote.Category == "service" {
+            file.callback = installer.create_service
+        }
+
+        files = append(files, file)
+    }
+    return files
+}
+
+/*
+    This method downloads a remote file with retries, an
+    interrupted download is resumed from the ".part" file.
+    Size and SHA256 are checked before the file is moved
+    to its destination, it returns the SHA256.
+*/
+func (installer *Installer) download_file(ctx context.Context, remote RemoteFile, destination string, permissions os.FileMode) (string, error) {
+    if remote.URL == "" || remote.Hash == "" {
+        return "", errors.New("remote file requires url and sha256")
+    }
+
+    partial := destination + ".part"
+    var err error
+    for attempt := 1; attempt <= download_retries; attempt++ {
+        err = installer.download_attempt(ctx, remote, partial)
+        if err == nil {
+            err = installer.verify_download(remote, partial)
+            if err == nil {
+                break
+            }
+            installer.target.Remove(partial)
+        }
+
+        if ctx.Err() != nil {
+            break
+        }
+
+        if attempt < download_retries {
+            select {
+            case <-time.After(time.Duration(attempt) * time.Second):
+            case <-ctx.Done():
+            }
+        }
+    }
+
+    if err != nil {
+        return "", fmt.Errorf("downloading %s: %v", remote.URL, err)
+    }
+
+    err = installer.target.Chmod(partial, permissions)
+    if err == nil {
+        err = installer.target.Rename(partial, destination)
+    }
+    return remote.Hash, err
+}
+
+/*
+    This method downloads missing bytes of a remote file,
+    it requests a range when the ".part" file exists.
+*/
+func (installer *Installer) download_attempt(ctx context.Context, remote RemoteFile, partial string) error {
+    file, err := installer.target.OpenFile(partial, os.O_WRONLY | os.O_CREATE, 0600)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    offset, err := file.Seek(0, io.SeekEnd)
+    if err != nil {
+        return err
+    }
+
+    if remote.Size > 0 && offset == remote.Size {
+        return nil
+    }
+
+    if remote.Size > 0 && offset > remote.Size {
+        offset = 0
+    }
+
+    request, err := http.NewRequestWithContext(ctx, http.MethodGet, remote.URL, nil)
+    if err != nil {
+        return err
+    }
+
+    if offset > 0 {
+        request.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+    }
+
+    response, err := http_client.Do(request)
+    if err != nil {
+        return err
+    }
+    defer response.Body.Close()
+
+    switch response.StatusCode {
+    case http.StatusPartialContent:
+        if offset == 0 {
+            return fmt.Errorf("unexpected partial content")
+        }
+    case http.StatusOK:
+        offset = 0
+    default:
+        return fmt.Errorf("HTTP status %s", response.Status)
+    }
+
+    err = file.Truncate(offset)
+    if err == nil {
+        _, err = file.Seek(offset, io.SeekStart)
+    }
+    if err != nil {
+        return err
+    }
+
+    _, err = io.CopyBuffer(file, response.Body, make([]byte, copy_buffer_size))
+    return err
+}
+
+/*
+    This method checks size and SHA256 of a downloaded file.
+*/
+func (installer *Installer) verify_download(remote RemoteFile, partial string) error {
+    information, err := installer.target.Stat(partial)
+    if err != nil {
+        return err
+    }
+
+    if remote.Size > 0 && information.Size() != remote.Size {
+        return fmt.Errorf("size %d doesn't match %d", information.Size(), remote.Size)
+    }
+
+    hash, err := installer.hash_file(partial)
+    if err != nil {
+        return err
+    }
+
+    if !strings.EqualFold(hash, remote.Hash) {
+        return fmt.Errorf("SHA256 %s doesn't match %s", hash, remote.Hash)
+    }
+    return nil
+}
@@ -0,0 +1,167 @@
+/*
+    This file tests remote files for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "net/http/httptest"
+    "testing/fstest"
+    "path/filepath"
+    "sync/atomic"
+    "net/http"
+    "context"
+    "strings"
+    "testing"
+    "errors"
+    "bytes"
+    "time"
+)
+
+/*
+    This function tests downloads: complete and resumed
+    downloads, retries after a server error and SHA256
+    checks (case-insensitive, a mismatch is an error).
+*/
+func TestDownloadFile(t *testing.T) {
+    content := []byte(strings.Repeat("remote file content ", 64))
+    hash := hash_data(content)
+
+    tests := []struct {
+        name string
+        partial int
+        failures int32
+        hash string
+        timeout time.Duration
+        requests int32
+        resumed bool
+        fails bool
+    }{
+        {name: "complete download", partial: -1, hash: hash, requests: 1},
+        {name: "resumed download", partial: 100, hash: hash, requests: 1, resumed: true},
+        {name: "retry after a server error", partial: -1, failures: 1, hash: hash, requests: 2},
+        {name: "upper case sha256", partial: -1, hash: strings.ToUpper(hash), requests: 1},
+        {name: "sha256 mismatch", partial: -1, hash: strings.Repeat("0", 64), timeout: 300 * time.Millisecond, fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            var requests atomic.Int32
+            var ranges atomic.Value
+            ranges.Store("")
+            server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+                if requests.Add(1) <= test.failures {
+                    http.Error(writer, "unavailable", http.StatusServiceUnavailable)
+                    return
+                }
+                ranges.Store(request.Header.Get("Range"))
+                http.ServeContent(writer, request, "file", time.Time{}, bytes.NewReader(content))
+            }))
+            defer server.Close()
+
+            target := NewMemoryFileSystem()
+            setup := new_test_installer(t, "", fstest.MapFS{}, target)
+            directory := filepath.FromSlash("/opt/testapp")
+            destination := filepath.Join(directory, "file")
+            err := target.MkdirAll(directory, 0755)
+            if err == nil && test.partial >= 0 {
+                err = write_target(target, destination + ".part", content[:test.partial], 0600)
+            }
+            if err != nil {
+                t.Fatal(err)
+            }
+
+            ctx := context.Background()
+            if test.timeout > 0 {
+                var cancel context.CancelFunc
+                ctx, cancel = context.WithTimeout(ctx, test.timeout)
+                defer cancel()
+            }
+
+            remote := RemoteFile{Category: "program", Name: "file", URL: server.URL, Size: int64(len(content)), Hash: test.hash}
+            _, err = setup.download_file(ctx, remote, destination, 0755)
+            if test.fails {
+                if err == nil {
+                    t.Fatalf("download_file: no error")
+                }
+                if _, err := target.Stat(destination); err == nil {
+                    t.Errorf("%s exists after a failed download", destination)
+                }
+                if information, err := target.Stat(destination + ".part"); err == nil && information.Size() != 0 {
+                    t.Errorf("the mismatching download is kept (%d bytes)", information.Size())
+                }
+                return
+            }
+
+            if err != nil {
+                t.Fatalf("download_file: %v", err)
+            }
+
+            written, err := target.ReadFile(destination)
+            if err != nil || !bytes.Equal(written, content) {
+                t.Errorf("downloaded content doesn't match: %v", err)
+            }
+            if requests.Load() != test.requests {
+                t.Errorf("%d requests, expected %d", requests.Load(), test.requests)
+            }
+            if resumed := ranges.Load().(string) != ""; resumed != test.resumed {
+                t.Errorf("range request: %q, expected resumed %t", ranges.Load(), test.resumed)
+            }
+        })
+    }
+}
+
+/*
+    This function tests the validation of manifest remote
+    files: categories and single file names.
+*/
+func TestValidateRemote(t *testing.T) {
+    tests := []struct {
+        name string
+        category string
+        file string
+        fails bool
+    }{
+        {name: "valid file", category: "program", file: "tool"},
+        {name: "unknown category", category: "systemd", file: "tool", fails: true},
+        {name: "empty category", category: "", file: "tool", fails: true},
+        {name: "parent directory", category: "program", file: "../tool", fails: true},
+        {name: "dot dot", category: "program", file: "..", fails: true},
+        {name: "sub directory", category: "config", file: "sub/tool.conf", fails: true},
+        {name: "windows separator", category: "config", file: `sub\tool.conf`, fails: true},
+        {name: "empty name", category: "data", file: "", fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            manifest := `{"remote": [{"category": "` + test.category + `", "name": "` + strings.ReplaceAll(test.file, `\`, `\\`) + `", "url": "http://localhost/tool", "sha256": "ABCDEF"}]}`
+            payload := fstest.MapFS{"manifest.json": &fstest.MapFile{Data: []byte(manifest)}}
+            setup, err := New(Options{Name: "testapp", Payload: payload, FileSystem: NewMemoryFileSystem()})
+
+            var installer_error *Error
+            if test.fails && (!errors.As(err, &installer_error) || installer_error.Code != ExitPayload) {
+                t.Fatalf("New: %v, expected a payload error", err)
+            }
+            if !test.fails && err != nil {
+                t.Fatalf("New: %v", err)
+            }
+            if !test.fails && setup.manifest.Remote[0].Hash != "abcdef" {
+                t.Errorf("sha256 %q is not saved in lower case", setup.manifest.Remote[0].Hash)
+            }
+        })
+    }
+}
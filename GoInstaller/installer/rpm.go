@@ -0,0 +1,533 @@
+/*
+    This file implements the RPM package export for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// installer export rpm package.rpm
+
+package installer
+
+import (
+    "encoding/binary"
+    "compress/gzip"
+    "crypto/sha256"
+    "encoding/hex"
+    "crypto/sha1"
+    "crypto/md5"
+    "strings"
+    "bytes"
+    "path"
+    "sort"
+    "time"
+    "math"
+    "fmt"
+    "io"
+    "os"
+)
+
+const (
+    rpm_int16 = 3
+    rpm_int32 = 4
+    rpm_int64 = 5
+    rpm_string = 6
+    rpm_bin = 7
+    rpm_string_array = 8
+    rpm_i18nstring = 9
+)
+
+const (
+    rpm_signatures = 62
+    rpm_immutable = 63
+)
+
+/*
+    The cpio "newc" format stores sizes on 32 bits.
+*/
+const cpio_max_size = 1 << 32 - 1
+
+/*
+    Files flags: config files are not replaced on upgrade
+    when they are locally modified (%config(noreplace)).
+*/
+const rpm_file_config = 1 | 16
+
+var rpm_architectures = map[string]string{
+    "all": "noarch",
+    "amd64": "x86_64",
+    "arm64": "aarch64",
+    "386": "i686",
+    "arm": "armv7hl",
+}
+
+type RpmEntry struct {
+    tag uint32
+    datatype uint32
+    count uint32
+    data []byte
+}
+
+type RpmHeader struct {
+    entries []RpmEntry
+}
+
+type RpmScriptlet struct {
+    tag uint32
+    program uint32
+    script string
+}
+
+/*
+    This method adds a tag to the header: strings, strings
+    arrays, int64, int32 and int16 arrays and binary values.
+*/
+func (header *RpmHeader) add(tag uint32, value any) {
+    var buffer bytes.Buffer
+    entry := RpmEntry{tag: tag}
+
+    switch value := value.(type) {
+    case string:
+        entry.datatype, entry.count = rpm_string, 1
+        buffer.WriteString(value + "\x00")
+    case []string:
+        entry.datatype, entry.count = rpm_string_array, uint32(len(value))
+        for _, item := range value {
+            buffer.WriteString(item + "\x00")
+        }
+    case []int64:
+        entry.datatype, entry.count = rpm_int64, uint32(len(value))
+        binary.Write(&buffer, binary.BigEndian, value)
+    case []int32:
+        entry.datatype, entry.count = rpm_int32, uint32(len(value))
+        binary.Write(&buffer, binary.BigEndian, value)
+    case []int16:
+        entry.datatype, entry.count = rpm_int16, uint32(len(value))
+        binary.Write(&buffer, binary.BigEndian, value)
+    case []byte:
+        entry.datatype, entry.count = rpm_bin, uint32(len(value))
+        buffer.Write(value)
+    }
+
+    entry.data = buffer.Bytes()
+    header.entries = append(header.entries, entry)
+}
+
+/*
+    This method adds a size: an int32 tag, or the int64
+    tag when the size doesn't fit in 32 bits.
+*/
+func (header *RpmHeader) add_size(tag uint32, long_tag uint32, size int64) {
+    if size > math.MaxInt32 {
+        header.add(long_tag, []int64{size})
+    } else {
+        header.add(tag, []int32{int32(size)})
+    }
+}
+
+/*
+    This method adds a translatable string (only the C locale).
+*/
+func (header *RpmHeader) add_i18n(tag uint32, value string) {
+    header.entries = append(header.entries, RpmEntry{tag, rpm_i18nstring, 1, []byte(value + "\x00")})
+}
+
+/*
+    This method returns the header structure: magic, index
+    entries and data store, all entries are in a region
+    (signatures or immutable) closed by a trailer entry.
+*/
+func (header *RpmHeader) bytes(region uint32) []byte {
+    sort.Slice(header.entries, func(i, j int) bool {
+        return header.entries[i].tag < header.entries[j].tag
+    })
+
+    var index, store bytes.Buffer
+    count := len(header.entries) + 1
+    for _, entry := range header.entries {
+        alignment := map[uint32]int{rpm_int16: 2, rpm_int32: 4, rpm_int64: 8}[entry.datatype]
+        for alignment > 0 && store.Len() % alignment != 0 {
+            store.WriteByte(0)
+        }
+
+        binary.Write(&index, binary.BigEndian, []uint32{entry.tag, entry.datatype, uint32(store.Len()), entry.count})
+        store.Write(entry.data)
+    }
+
+    trailer := store.Len()
+    binary.Write(&store, binary.BigEndian, []int32{int32(region), rpm_bin, int32(-count * 16), 16})
+
+    var output bytes.Buffer
+    output.Write([]byte{0x8e, 0xad, 0xe8, 0x01, 0, 0, 0, 0})
+    binary.Write(&output, binary.BigEndian, []uint32{uint32(count), uint32(store.Len())})
+    binary.Write(&output, binary.BigEndian, []uint32{region, rpm_bin, uint32(trailer), 16})
+    output.Write(index.Bytes())
+    output.Write(store.Bytes())
+    return output.Bytes()
+}
+
+/*
+    This method writes a RPM package (v4 header format) without
+    rpmbuild: the lead, the signature header (sizes and digests),
+    the main header and the gzip compressed cpio payload.
+    Scriptlets run the Linux commands (%post) and manage systemd
+    units (%preun, %postun), config files are %config(noreplace).
+    Data files are created by %post only when they are missing.
+*/
+func (installer *Installer) write_rpm(output string) error {
+    files, defaults := installer.stage_data_files(installer.package_files())
+    files = installer.rpm_files(files)
+    modified := package_time()
+
+    payload_file, err := os.CreateTemp("", "goinstaller-*.cpio.gz")
+    if err != nil {
+        return err
+    }
+    defer os.Remove(payload_file.Name())
+    defer payload_file.Close()
+
+    digests, payload_size, err := write_rpm_payload(payload_file, files, modified)
+    if err != nil {
+        return err
+    }
+
+    header := installer.rpm_header(files, defaults, digests, modified).bytes(rpm_immutable)
+
+    hasher := md5.New()
+    hasher.Write(header)
+    _, err = payload_file.Seek(0, io.SeekStart)
+    if err != nil {
+        return err
+    }
+
+    compressed_size, err := io.Copy(hasher, payload_file)
+    if err != nil {
+        return err
+    }
+
+    header_sha1 := sha1.Sum(header)
+    header_sha256 := sha256.Sum256(header)
+    signature := &RpmHeader{}
+    signature.add(269, hex.EncodeToString(header_sha1[:]))
+    signature.add(273, hex.EncodeToString(header_sha256[:]))
+    signature.add_size(1000, 270, int64(len(header)) + compressed_size)
+    signature.add(1004, hasher.Sum(nil))
+    signature.add_size(1007, 271, payload_size)
+    signature_bytes := signature.bytes(rpm_signatures)
+    signature_bytes = append(signature_bytes, make([]byte, (8 - len(signature_bytes) % 8) % 8)...)
+
+    file, err := os.Create(output)
+    if err != nil {
+        return err
+    }
+    defer file.Close()
+
+    _, err = file.Write(installer.rpm_lead())
+    if err == nil {
+        _, err = file.Write(signature_bytes)
+    }
+    if err == nil {
+        _, err = file.Write(header)
+    }
+    if err == nil {
+        _, err = payload_file.Seek(0, io.SeekStart)
+    }
+    if err == nil {
+        _, err = io.Copy(file, payload_file)
+    }
+    if err != nil {
+        return err
+    }
+    return file.Close()
+}
+
+/*
+    This method returns the RPM lead (96 bytes), only
+    kept for compatibility with old tools.
+*/
+func (installer *Installer) rpm_lead() []byte {
+    lead := make([]byte, 96)
+    copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+    binary.BigEndian.PutUint16(lead[6:], 0)
+    binary.BigEndian.PutUint16(lead[8:], 1)
+    copy(lead[10:75], installer.package_name() + "-" + installer.package_version() + "-1")
+    binary.BigEndian.PutUint16(lead[76:], 1)
+    binary.BigEndian.PutUint16(lead[78:], 5)
+    return lead
+}
+
+/*
+    This method returns files owned by the RPM package sorted
+    by path, directories without the application name as a
+    path element (like /usr/local/bin) are shared system
+    directories, not owned.
+*/
+func (installer *Installer) rpm_files(files []PackageFile) []PackageFile {
+    var owned []PackageFile
+    for _, file := range files {
+        if !file.directory || !installer.shared_directory(file.path) {
+            owned = append(owned, file)
+        }
+    }
+
+    sort.SliceStable(owned, func(i, j int) bool {
+        return owned[i].path < owned[j].path
+    })
+    return owned
+}
+
+/*
+    This function returns the RPM file mode with the file type.
+*/
+func rpm_mode(file PackageFile) uint32 {
+    if file.directory {
+        return 0040000 | uint32(file.mode.Perm())
+    }
+    if file.link != "" {
+        return 0120000 | uint32(file.mode.Perm())
+    }
+    return 0100000 | uint32(file.mode.Perm())
+}
+
+/*
+    This function writes the gzip compressed cpio (newc) payload
+    and returns files SHA256 and the uncompressed payload size,
+    files of 4 GiB or more can't be written in a newc archive.
+*/
+func write_rpm_payload(writer io.Writer, files []PackageFile, modified time.Time) ([]string, int64, error) {
+    compressor, _ := gzip.NewWriterLevel(writer, gzip.BestCompression)
+    counter := &counting_writer{}
+    output := io.MultiWriter(compressor, counter)
+
+    digests := make([]string, len(files))
+    for index, file := range files {
+        size := file.size
+        if file.link != "" {
+            size = int64(len(file.link))
+        } else if file.directory {
+            size = 0
+        }
+
+        if size > cpio_max_size {
+            return nil, 0, fmt.Errorf("%s is too large for the cpio payload (4 GiB)", file.path)
+        }
+
+        err := write_cpio_header(output, "." + file.path, uint32(index + 1), rpm_mode(file), size, modified)
+        if err != nil {
+            return nil, 0, err
+        }
+
+        if file.link != "" {
+            _, err = io.WriteString(output, file.link)
+        } else if !file.directory {
+            hasher := sha256.New()
+            var source io.ReadCloser
+            source, err = file.open()
+            if err == nil {
+                _, err = io.CopyBuffer(io.MultiWriter(output, hasher), source, make([]byte, copy_buffer_size))
+                source.Close()
+            }
+            digests[index] = hex.EncodeToString(hasher.Sum(nil))
+        }
+
+        if err == nil {
+            _, err = output.Write(make([]byte, (4 - size % 4) % 4))
+        }
+        if err != nil {
+            return nil, 0, err
+        }
+    }
+
+    err := write_cpio_header(output, "TRAILER!!!", 0, 0, 0, time.Unix(0, 0))
+    if err == nil {
+        err = compressor.Close()
+    }
+    return digests, counter.size, err
+}
+
+/*
+    This function writes a cpio "newc" header with the
+    file name, padded on 4 bytes.
+*/
+func write_cpio_header(writer io.Writer, name string, inode uint32, mode uint32, size int64, modified time.Time) error {
+    nlink := 1
+    if mode & 0040000 != 0 && mode & 0100000 == 0 {
+        nlink = 2
+    }
+
+    header := fmt.Sprintf("070701%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%08x%s\x00", inode, mode, 0, 0, nlink, modified.Unix(), size, 0, 0, 0, 0, len(name) + 1, 0, name)
+    header += strings.Repeat("\x00", (4 - len(header) % 4) % 4)
+    _, err := io.WriteString(writer, header)
+    return err
+}
+
+/*
+    This method returns the RPM main header: package
+    metadata, files list, dependencies and scriptlets,
+    defaults are commands copying missing data files.
+*/
+func (installer *Installer) rpm_header(files []PackageFile, defaults string, digests []string, modified time.Time) *RpmHeader {
+    architecture := installer.package_architecture(rpm_architectures)
+
+    hostname, _ := os.Hostname()
+    header := &RpmHeader{}
+    header.add(1000, installer.package_name())
+    header.add(1001, strings.ReplaceAll(installer.package_version(), "-", "_"))
+    header.add(1002, "1")
+    header.add_i18n(1004, strings.SplitN(strings.TrimSpace(installer.package_description()), "\n", 2)[0])
+    header.add_i18n(1005, installer.package_description())
+    header.add(1006, []int32{int32(modified.Unix())})
+    header.add(1007, hostname)
+    header.add(1014, installer.package_license())
+    header.add_i18n(1016, "Unspecified")
+    header.add(1021, "linux")
+    header.add(1022, architecture)
+    header.add(1064, "4.16.0")
+    header.add(1124, "cpio")
+    header.add(1125, "gzip")
+    header.add(1126, "9")
+    header.add(5011, []int32{8})
+
+    var size int64
+    var sizes []int64
+    var mtimes, flags, inodes, devices, indexes []int32
+    var modes, rdevs []int16
+    var links, users, languages, basenames, directories []string
+    directory_indexes := make(map[string]int32)
+    for index, file := range files {
+        if file.directory {
+            sizes = append(sizes, 4096)
+        } else if file.link != "" {
+            sizes = append(sizes, int64(len(file.link)))
+        } else {
+            size += file.size
+            sizes = append(sizes, file.size)
+        }
+
+        flag := int32(0)
+        if file.config {
+            flag = rpm_file_config
+        }
+
+        directory := path.Dir(file.path) + "/"
+        if _, ok := directory_indexes[directory]; !ok {
+            directory_indexes[directory] = int32(len(directories))
+            directories = append(directories, directory)
+        }
+
+        modes = append(modes, int16(rpm_mode(file)))
+        rdevs = append(rdevs, 0)
+        mtimes = append(mtimes, int32(modified.Unix()))
+        flags = append(flags, flag)
+        inodes = append(inodes, int32(index + 1))
+        devices = append(devices, 1)
+        links = append(links, file.link)
+        users = append(users, "root")
+        languages = append(languages, "")
+        basenames = append(basenames, path.Base(file.path))
+        indexes = append(indexes, directory_indexes[directory])
+    }
+
+    large := size > math.MaxInt32
+    header.add_size(1009, 5009, size)
+    if len(files) > 0 && large {
+        header.add(5008, sizes)
+    } else if len(files) > 0 {
+        header.add(1028, int32_sizes(sizes))
+    }
+
+    if len(files) > 0 {
+        header.add(1030, modes)
+        header.add(1033, rdevs)
+        header.add(1034, mtimes)
+        header.add(1035, digests)
+        header.add(1036, links)
+        header.add(1037, flags)
+        header.add(1039, users)
+        header.add(1040, users)
+        header.add(1095, devices)
+        header.add(1096, inodes)
+        header.add(1097, languages)
+        header.add(1116, indexes)
+        header.add(1117, basenames)
+        header.add(1118, directories)
+    }
+
+    header.add(1047, []string{installer.package_name()})
+    header.add(1112, []int32{8})
+    header.add(1113, []string{strings.ReplaceAll(installer.package_version(), "-", "_") + "-1"})
+
+    requires := []string{"rpmlib(CompressedFileNames)", "rpmlib(FileDigests)", "rpmlib(PayloadFilesHavePrefix)"}
+    requires_versions := []string{"3.0.4-1", "4.6.0-1", "4.0-1"}
+    requires_flags := []int32{(1 << 24) | 8 | 2, (1 << 24) | 8 | 2, (1 << 24) | 8 | 2}
+    if large {
+        requires = append(requires, "rpmlib(LargeFiles)")
+        requires_versions = append(requires_versions, "4.12.0-1")
+        requires_flags = append(requires_flags, (1 << 24) | 8 | 2)
+    }
+
+    units := package_units(files)
+    scriptlets := []RpmScriptlet{
+        {1024, 1086, defaults + installer.post_install_script(units)},
+        {1025, 1087, rpm_script("$1", "0", pre_remove_script(units))},
+    }
+    if len(units) > 0 {
+        scriptlets = append(scriptlets, RpmScriptlet{1026, 1088, "systemctl daemon-reload || true\n"})
+    }
+
+    interpreter := false
+    for _, scriptlet := range scriptlets {
+        if scriptlet.script == "" {
+            continue
+        }
+
+        header.add(scriptlet.tag, scriptlet.script)
+        header.add(scriptlet.program, "/bin/sh")
+        interpreter = true
+    }
+
+    if interpreter {
+        requires = append(requires, "/bin/sh")
+        requires_versions = append(requires_versions, "")
+        requires_flags = append(requires_flags, 1 << 8)
+    }
+
+    header.add(1048, requires_flags)
+    header.add(1049, requires)
+    header.add(1050, requires_versions)
+    return header
+}
+
+/*
+    This function returns sizes as int32, it's used
+    when the total size is less than 2 GiB.
+*/
+func int32_sizes(sizes []int64) []int32 {
+    values := make([]int32, len(sizes))
+    for index, size := range sizes {
+        values[index] = int32(size)
+    }
+    return values
+}
+
+/*
+    This function returns a scriptlet running the
+    body when the argument has the value.
+*/
+func rpm_script(argument string, value string, body string) string {
+    if body == "" {
+        return ""
+    }
+    return "if [ \"" + argument + "\" = \"" + value + "\" ]; then\n" + body + "fi\n"
+}
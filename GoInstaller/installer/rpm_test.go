@@ -0,0 +1,302 @@
+/*
+    This file tests RPM packages for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "encoding/binary"
+    "testing/fstest"
+    "compress/gzip"
+    "path/filepath"
+    "strings"
+    "testing"
+    "bytes"
+    "time"
+    "io"
+    "os"
+)
+
+/*
+    This function parses a RPM header structure and returns
+    its entries by tag and the offset of the next structure.
+*/
+func read_rpm_header(t *testing.T, content []byte, offset int) (map[uint32]RpmEntry, int) {
+    t.Helper()
+    if len(content) < offset + 16 || !bytes.Equal(content[offset:offset + 4], []byte{0x8e, 0xad, 0xe8, 0x01}) {
+        t.Fatalf("invalid header magic at %d", offset)
+    }
+
+    count := int(binary.BigEndian.Uint32(content[offset + 8:]))
+    size := int(binary.BigEndian.Uint32(content[offset + 12:]))
+    index := offset + 16
+    store := index + count * 16
+    if len(content) < store + size {
+        t.Fatalf("truncated header at %d", offset)
+    }
+
+    entries := make(map[uint32]RpmEntry)
+    for position := index; position < store; position += 16 {
+        tag := binary.BigEndian.Uint32(content[position:])
+        datatype := binary.BigEndian.Uint32(content[position + 4:])
+        start := int(binary.BigEndian.Uint32(content[position + 8:]))
+        entries[tag] = RpmEntry{tag: tag, datatype: datatype, count: binary.BigEndian.Uint32(content[position + 12:]), data: content[store + start:store + size]}
+    }
+    return entries, store + size
+}
+
+/*
+    This function returns the string value of a header entry.
+*/
+func rpm_string_value(entry RpmEntry) string {
+    value, _, _ := strings.Cut(string(entry.data), "\x00")
+    return value
+}
+
+/*
+    This function returns the values of a string array entry.
+*/
+func rpm_strings_value(entry RpmEntry) []string {
+    values := strings.Split(string(entry.data), "\x00")
+    if len(values) < int(entry.count) {
+        return values
+    }
+    return values[:entry.count]
+}
+
+/*
+    This function returns paths of files in the main header.
+*/
+func rpm_paths(header map[uint32]RpmEntry) []string {
+    basenames := rpm_strings_value(header[1117])
+    directories := rpm_strings_value(header[1118])
+    indexes := header[1116]
+
+    var paths []string
+    for index, name := range basenames {
+        directory := binary.BigEndian.Uint32(indexes.data[index * 4:])
+        paths = append(paths, directories[directory] + name)
+    }
+    return paths
+}
+
+/*
+    This function tests the RPM package structure: lead,
+    signature and main headers, sizes, the license and
+    architecture tags, data files staged in the default
+    data directory and copied by %post and the cpio payload.
+*/
+func TestWriteRpm(t *testing.T) {
+    tests := []struct {
+        name string
+        manifest string
+        license string
+        architecture string
+    }{
+        {name: "license name", manifest: `{"license_name": "GPL-3.0-or-later", "architecture": "amd64"}`, license: "GPL-3.0-or-later", architecture: "x86_64"},
+        {name: "short license", manifest: `{"license": "MIT", "architecture": "arm64"}`, license: "MIT", architecture: "aarch64"},
+        {name: "license text", manifest: `{"license": "Copyright (C) 2025\nAll rights reserved.", "architecture": "all"}`, license: "Proprietary", architecture: "noarch"},
+        {name: "unmapped architecture", manifest: `{"architecture": "riscv64"}`, license: "Proprietary", architecture: "riscv64"},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            payload := fstest.MapFS{"program/app": test_file("binary"), "config/app.conf": test_file("key=value"), "data/state.db": test_file("state")}
+            setup, output := export_test_package(t, test.manifest, payload, "rpm")
+
+            content, err := os.ReadFile(output)
+            if err != nil {
+                t.Fatal(err)
+            }
+            if len(content) < 96 || !bytes.Equal(content[:4], []byte{0xed, 0xab, 0xee, 0xdb}) {
+                t.Fatalf("invalid lead magic")
+            }
+
+            signature, offset := read_rpm_header(t, content, 96)
+            offset += (8 - offset % 8) % 8
+            header, payload_offset := read_rpm_header(t, content, offset)
+
+            if size := binary.BigEndian.Uint32(signature[1000].data); int(size) != len(content) - offset {
+                t.Errorf("signature size %d, expected %d", size, len(content) - offset)
+            }
+            if name := rpm_string_value(header[1000]); name != setup.package_name() {
+                t.Errorf("package name %q, expected %q", name, setup.package_name())
+            }
+            if license := rpm_string_value(header[1014]); license != test.license {
+                t.Errorf("license %q, expected %q", license, test.license)
+            }
+            if architecture := rpm_string_value(header[1022]); architecture != test.architecture {
+                t.Errorf("architecture %q, expected %q", architecture, test.architecture)
+            }
+            state := filepath.ToSlash(filepath.Join(setup.os_layout("linux").Data, "state.db"))
+            staged := "/usr/share/" + setup.package_name() + "/default-data" + state
+            paths := rpm_paths(header)
+            if contains(paths, state) || !contains(paths, staged) {
+                t.Errorf("the data file is not staged in %s: %v", staged, paths)
+            }
+            if line := "[ -e " + shell_quote(state) + " ] || cp -p " + shell_quote(staged) + " " + shell_quote(state) + "\n"; !strings.Contains(rpm_string_value(header[1024]), line) {
+                t.Errorf("%%post doesn't copy the data file:\n%s", rpm_string_value(header[1024]))
+            }
+
+            if header[1009].datatype != rpm_int32 || header[1028].datatype != rpm_int32 {
+                t.Errorf("sizes of a small package are not int32 tags")
+            }
+            if _, ok := header[5009]; ok {
+                t.Errorf("64-bit size tag in a small package")
+            }
+
+            decompressor, err := gzip.NewReader(bytes.NewReader(content[payload_offset:]))
+            if err != nil {
+                t.Fatalf("invalid payload: %v", err)
+            }
+            cpio, err := io.ReadAll(decompressor)
+            if err != nil || !bytes.HasPrefix(cpio, []byte("070701")) || !bytes.Contains(cpio, []byte("TRAILER!!!")) {
+                t.Errorf("invalid cpio payload: %v", err)
+            }
+            if size := binary.BigEndian.Uint32(signature[1007].data); int(size) != len(cpio) {
+                t.Errorf("payload size %d, expected %d", size, len(cpio))
+            }
+        })
+    }
+}
+
+/*
+    This function tests files owned by the package: shared
+    directories are not owned, the application name must
+    be a path element.
+*/
+func TestRpmFiles(t *testing.T) {
+    tests := []struct {
+        name string
+        file PackageFile
+        owned bool
+    }{
+        {name: "shared directory", file: PackageFile{path: "/usr/local/bin", directory: true}},
+        {name: "name prefix", file: PackageFile{path: "/usr/local/binaries", directory: true}},
+        {name: "application directory", file: PackageFile{path: "/opt/bin", directory: true}, owned: true},
+        {name: "application sub directory", file: PackageFile{path: "/var/lib/bin/cache", directory: true}, owned: true},
+        {name: "file in a shared directory", file: PackageFile{path: "/usr/local/bin/bin"}, owned: true},
+    }
+
+    setup := &Installer{name: "bin"}
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            owned := len(setup.rpm_files([]PackageFile{test.file})) == 1
+            if owned != test.owned {
+                t.Errorf("%s owned: %t, expected %t", test.file.path, owned, test.owned)
+            }
+        })
+    }
+}
+
+/*
+    This function tests sizes tags: int32 tags for sizes
+    below 2 GiB and int64 tags for larger sizes.
+*/
+func TestRpmAddSize(t *testing.T) {
+    tests := []struct {
+        name string
+        size int64
+        tag uint32
+        datatype uint32
+    }{
+        {name: "empty", size: 0, tag: 1000, datatype: rpm_int32},
+        {name: "small", size: 4096, tag: 1000, datatype: rpm_int32},
+        {name: "largest int32", size: 1 << 31 - 1, tag: 1000, datatype: rpm_int32},
+        {name: "2 GiB", size: 1 << 31, tag: 270, datatype: rpm_int64},
+        {name: "large", size: 5 << 32, tag: 270, datatype: rpm_int64},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            header := &RpmHeader{}
+            header.add_size(1000, 270, test.size)
+            if len(header.entries) != 1 {
+                t.Fatalf("%d entries, expected 1", len(header.entries))
+            }
+
+            entry := header.entries[0]
+            if entry.tag != test.tag || entry.datatype != test.datatype || entry.count != 1 {
+                t.Fatalf("tag %d type %d count %d, expected tag %d type %d", entry.tag, entry.datatype, entry.count, test.tag, test.datatype)
+            }
+
+            value := int64(int32(binary.BigEndian.Uint32(entry.data)))
+            if entry.datatype == rpm_int64 {
+                value = int64(binary.BigEndian.Uint64(entry.data))
+            }
+            if value != test.size {
+                t.Errorf("size %d, expected %d", value, test.size)
+            }
+
+            structure := header.bytes(rpm_signatures)
+            entries, _ := read_rpm_header(t, structure, 0)
+            if entry.datatype == rpm_int64 && binary.BigEndian.Uint32(structure[32 + 8:]) % 8 != 0 {
+                t.Errorf("int64 data is not aligned on 8 bytes")
+            }
+            if _, ok := entries[rpm_signatures]; !ok {
+                t.Errorf("no region trailer")
+            }
+        })
+    }
+}
+
+/*
+    This function tests the cpio size limit: files of
+    4 GiB or more are refused.
+*/
+func TestRpmPayloadSizeLimit(t *testing.T) {
+    tests := []struct {
+        name string
+        size int64
+        fails bool
+    }{
+        {name: "empty file", size: 0},
+        {name: "4 GiB file", size: cpio_max_size + 1, fails: true},
+        {name: "8 GiB file", size: 2 << 32, fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            file := PackageFile{
+                path: "/opt/testapp/file",
+                mode: 0644,
+                size: test.size,
+                open: func() (io.ReadCloser, error) {
+                    return io.NopCloser(io.LimitReader(zero_reader{}, test.size)), nil
+                },
+            }
+
+            _, _, err := write_rpm_payload(io.Discard, []PackageFile{file}, time.Unix(0, 0))
+            if test.fails != (err != nil) {
+                t.Fatalf("write_rpm_payload: %v, expected failure: %t", err, test.fails)
+            }
+        })
+    }
+}
+
+/*
+    A reader of zero bytes.
+*/
+type zero_reader struct{}
+
+/*
+    This method fills the buffer with zero bytes.
+*/
+func (zero_reader) Read(data []byte) (int, error) {
+    clear(data)
+    return len(data), nil
+}
@@ -0,0 +1,247 @@
+/*
+    This file implements services management for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "runtime"
+    "context"
+    "time"
+    "fmt"
+    "os"
+)
+
+/*
+    Default time to wait for a service to stop.
+*/
+const default_stop_timeout = 30 * time.Second
+
+/*
+    The system service manager: systemd on Linux and the
+    Service Control Manager on Windows.
+*/
+type ServiceManager interface {
+    Active(ctx context.Context, name string) (bool, error)
+    Stop(ctx context.Context, name string, timeout time.Duration) error
+    Start(ctx context.Context, name string) error
+    Enable(ctx context.Context, names []string, start bool) error
+    Disable(ctx context.Context, names []string) error
+    Reload(ctx context.Context) error
+}
+
+/*
+    A service manager doing nothing, it's used when the
+    target is not the OS filesystem.
+*/
+type NoServiceManager struct{}
+
+/*
+    This method returns false: there is no running service.
+*/
+func (NoServiceManager) Active(ctx context.Context, name string) (bool, error) {
+    return false, nil
+}
+
+/*
+    This method does nothing.
+*/
+func (NoServiceManager) Stop(ctx context.Context, name string, timeout time.Duration) error {
+    return nil
+}
+
+/*
+    This method does nothing.
+*/
+func (NoServiceManager) Start(ctx context.Context, name string) error {
+    return nil
+}
+
+/*
+    This method does nothing.
+*/
+func (NoServiceManager) Enable(ctx context.Context, names []string, start bool) error {
+    return nil
+}
+
+/*
+    This method does nothing.
+*/
+func (NoServiceManager) Disable(ctx context.Context, names []string) error {
+    return nil
+}
+
+/*
+    This method does nothing.
+*/
+func (NoServiceManager) Reload(ctx context.Context) error {
+    return nil
+}
+
+/*
+    This method returns services owned by an install
+    receipt: installed systemd units on Linux and the
+    application service on Windows.
+*/
+func (installer *Installer) owned_services(receipt Receipt) []string {
+    var names []string
+    for _, entry := range receipt.Files {
+        if entry.Category != "service" {
+            continue
+        }
+
+        name := filepath.Base(entry.Path)
+        if runtime.GOOS == "windows" {
+            return []string{installer.name}
+        } else if systemd_unit(name) && !contains(names, name) {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+/*
+    This method returns running services owned by
+    the previous install.
+*/
+func (installer *Installer) active_services(ctx context.Context) []string {
+    var names []string
+    for _, name := range installer.owned_services(installer.previous_receipt) {
+        active, err := installer.services.Active(ctx, name)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error checking service %s: %v\n", name, err)
+        }
+        if active {
+            names = append(names, name)
+        }
+    }
+    return names
+}
+
+/*
+    This method stops running services owned by the previous
+    install before files are replaced, a service not stopped
+    before the stop timeout (Options.ServiceStopTimeout)
+    fails the install.
+*/
+func (installer *Installer) stop_services(ctx context.Context) error {
+    timeout := installer.stop_timeout()
+    for _, name := range installer.active_services(ctx) {
+        fmt.Printf("Stopping service: %s\n", name)
+        err := installer.services.Stop(ctx, name, timeout)
+        if err != nil {
+            return failure(ExitService, "stopping service %s: %v", name, err)
+        }
+        installer.stopped_services = append(installer.stopped_services, name)
+    }
+    return nil
+}
+
+/*
+    This method starts services stopped before the files
+    replacement, errors are printed: installed files are kept.
+*/
+func (installer *Installer) restart_services(ctx context.Context) {
+    if len(installer.stopped_services) == 0 {
+        return
+    }
+
+    err := installer.services.Reload(ctx)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reloading services: %v\n", err)
+    }
+
+    for _, name := range installer.stopped_services {
+        err := installer.services.Start(ctx, name)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error restarting service %s: %v\n", name, err)
+            continue
+        }
+        fmt.Printf("Restarted service: %s\n", name)
+    }
+    installer.stopped_services = nil
+}
+
+/*
+    This method stops and disables services before the
+    uninstall removes their files, errors are printed.
+*/
+func (installer *Installer) disable_services(ctx context.Context, names []string) {
+    if len(names) == 0 {
+        return
+    }
+
+    err := installer.services.Disable(ctx, names)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error disabling services: %v\n", err)
+        return
+    }
+    for _, name := range names {
+        fmt.Printf("Disabled service: %s\n", name)
+    }
+}
+
+/*
+    This method returns the time to wait for a service to stop.
+*/
+func (installer *Installer) stop_timeout() time.Duration {
+    if installer.options.ServiceStopTimeout <= 0 {
+        return default_stop_timeout
+    }
+    return installer.options.ServiceStopTimeout
+}
+
+/*
+    This method stops services started by the install when
+    the install is rolled back, errors are printed.
+*/
+func (installer *Installer) stop_started_services(ctx context.Context) {
+    timeout := installer.stop_timeout()
+    for _, name := range installer.started_services {
+        err := installer.services.Stop(ctx, name, timeout)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error stopping service %s: %v\n", name, err)
+            continue
+        }
+        fmt.Printf("Stopped service: %s\n", name)
+    }
+    installer.started_services = nil
+}
+
+/*
+    This method returns services to stop before the
+    files replacement and to restart after commands.
+*/
+func (installer *Installer) plan_stop_services(ctx context.Context) ([]Action, error) {
+    var actions []Action
+    for _, name := range installer.active_services(ctx) {
+        actions = append(actions, Action{Kind: "stop", Path: name})
+    }
+    return actions, nil
+}
+
+/*
+    This method returns services to restart after commands.
+*/
+func (installer *Installer) plan_restart_services(ctx context.Context) ([]Action, error) {
+    var actions []Action
+    for _, name := range installer.active_services(ctx) {
+        actions = append(actions, Action{Kind: "restart", Path: name})
+    }
+    return actions, nil
+}
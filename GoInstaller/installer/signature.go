@@ -0,0 +1,138 @@
+/*
+    This file implements the payload signature for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "crypto/ed25519"
+    "encoding/hex"
+    "strings"
+    "errors"
+    "bytes"
+    "io/fs"
+)
+
+/*
+    This method checks the ed25519 signature of the payload
+    manifest (Options.PublicKey) and the hash of every payload
+    file (a missing category directory is empty), it returns
+    an error when the installer has been modified. Payload
+    hashes are saved to check files when they are written.
+*/
+func (installer *Installer) verify_payload() error {
+    if installer.options.PublicKey == "" {
+        return nil
+    }
+
+    public_key, err := hex.DecodeString(installer.options.PublicKey)
+    if err != nil || len(public_key) != ed25519.PublicKeySize {
+        return failure(ExitSignature, "invalid pinned public key")
+    }
+
+    sums, err := installer.read_signature_file("signature/payload.sums")
+    if err != nil {
+        return failure(ExitSignature, "reading payload manifest: %v", err)
+    }
+
+    signature, err := installer.read_signature_file("signature/payload.sig")
+    if err == nil {
+        signature, err = hex.DecodeString(string(bytes.TrimSpace(signature)))
+    }
+    if err != nil || !ed25519.Verify(ed25519.PublicKey(public_key), sums, signature) {
+        return failure(ExitSignature, "invalid payload manifest signature")
+    }
+
+    installer.payload_hashes = parse_sums(sums)
+    verified := 0
+    for _, category := range categories {
+        entries, err := category_entries(installer.payload, category)
+        if err != nil {
+            return failure(ExitSignature, "reading embedded files (%s): %v", category, err)
+        }
+
+        for _, entry := range entries {
+            name := category + "/" + entry.Name()
+            file, err := installer.payload.Open(name)
+            if err != nil {
+                return failure(ExitSignature, "reading file %s: %v", name, err)
+            }
+            hash, err := hash_reader(file)
+            file.Close()
+
+            err = installer.verify_hash(name, hash, err)
+            if err != nil {
+                return err
+            }
+            verified++
+        }
+    }
+
+    err = installer.verify_hash("manifest.json", hash_data(installer.manifest_data), nil)
+    if err != nil {
+        return err
+    }
+    verified++
+
+    if verified != len(installer.payload_hashes) {
+        return failure(ExitSignature, "payload files are missing from the installer")
+    }
+    return nil
+}
+
+/*
+    This method reads a signature file from the payload
+    archive or from Options.Signature files.
+*/
+func (installer *Installer) read_signature_file(name string) ([]byte, error) {
+    content, err := fs.ReadFile(installer.payload, name)
+    if err == nil {
+        return content, nil
+    }
+
+    if installer.options.Signature == nil {
+        return nil, errors.New("no signature files")
+    }
+    return fs.ReadFile(installer.options.Signature, name)
+}
+
+/*
+    This method returns an error when the hash of a
+    payload file doesn't match the signed manifest.
+*/
+func (installer *Installer) verify_hash(name string, hash string, err error) error {
+    expected, ok := installer.payload_hashes[name]
+    if err != nil || !ok || hash != expected {
+        return failure(ExitSignature, "payload file doesn't match the signed manifest: %s", name)
+    }
+    return nil
+}
+
+/*
+    This function parses a manifest of hashes using
+    the sha256sum format: "<hash>  <path>" lines.
+*/
+func parse_sums(sums []byte) map[string]string {
+    hashes := make(map[string]string)
+    for _, line := range strings.Split(string(sums), "\n") {
+        hash, name, ok := strings.Cut(line, "  ")
+        if ok {
+            hashes[name] = hash
+        }
+    }
+    return hashes
+}
@@ -0,0 +1,139 @@
+/*
+    This file tests the payload signature for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "crypto/ed25519"
+    "testing/fstest"
+    "encoding/hex"
+    "crypto/rand"
+    "strings"
+    "testing"
+    "errors"
+    "sort"
+)
+
+/*
+    This function signs a test payload like the packer
+    (goinstaller -sign) does.
+*/
+func sign_test_payload(payload fstest.MapFS, private_key ed25519.PrivateKey) {
+    var names []string
+    for name := range payload {
+        names = append(names, name)
+    }
+    sort.Strings(names)
+
+    var sums strings.Builder
+    for _, name := range names {
+        sums.WriteString(hash_data(payload[name].Data) + "  " + name + "\n")
+    }
+
+    signature := ed25519.Sign(private_key, []byte(sums.String()))
+    payload["signature/payload.sums"] = test_file(sums.String())
+    payload["signature/payload.sig"] = test_file(hex.EncodeToString(signature) + "\n")
+}
+
+/*
+    This function tests the payload signature: a modified
+    payload file, a modified manifest of hashes, an added
+    file or another public key are rejected.
+*/
+func TestVerifyPayload(t *testing.T) {
+    public_key, private_key, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+    other_key, _, err := ed25519.GenerateKey(rand.Reader)
+    if err != nil {
+        t.Fatal(err)
+    }
+
+    tests := []struct {
+        name string
+        public_key ed25519.PublicKey
+        tamper func(payload fstest.MapFS)
+        no_config bool
+        fails bool
+    }{
+        {name: "valid signature", public_key: public_key},
+        {name: "missing category directory", public_key: public_key, no_config: true},
+        {
+            name: "tampered file",
+            public_key: public_key,
+            tamper: func(payload fstest.MapFS) { payload["program/app"] = test_file("malware") },
+            fails: true,
+        },
+        {
+            name: "tampered manifest",
+            public_key: public_key,
+            tamper: func(payload fstest.MapFS) { payload["manifest.json"] = test_file(`{"workers": 2}`) },
+            fails: true,
+        },
+        {
+            name: "tampered sums",
+            public_key: public_key,
+            tamper: func(payload fstest.MapFS) {
+                sums := string(payload["signature/payload.sums"].Data)
+                payload["program/app"] = test_file("malware")
+                payload["signature/payload.sums"] = test_file(strings.Replace(sums, hash_data([]byte("binary")), hash_data([]byte("malware")), 1))
+            },
+            fails: true,
+        },
+        {
+            name: "added file",
+            public_key: public_key,
+            tamper: func(payload fstest.MapFS) { payload["program/tool"] = test_file("malware") },
+            fails: true,
+        },
+        {
+            name: "removed file",
+            public_key: public_key,
+            tamper: func(payload fstest.MapFS) { delete(payload, "program/app") },
+            fails: true,
+        },
+        {name: "other public key", public_key: other_key, fails: true},
+    }
+
+    for _, test := range tests {
+        t.Run(test.name, func(t *testing.T) {
+            payload := fstest.MapFS{
+                "manifest.json": test_file("{}"),
+                "program/app": test_file("binary"),
+            }
+            if !test.no_config {
+                payload["config/app.conf"] = test_file("key=value")
+            }
+            sign_test_payload(payload, private_key)
+            if test.tamper != nil {
+                test.tamper(payload)
+            }
+
+            _, err := New(Options{Name: "testapp", Payload: payload, FileSystem: NewMemoryFileSystem(), PublicKey: hex.EncodeToString(test.public_key)})
+
+            var installer_error *Error
+            if test.fails && (!errors.As(err, &installer_error) || installer_error.Code != ExitSignature) {
+                t.Fatalf("New: %v, expected a signature error", err)
+            }
+            if !test.fails && err != nil {
+                t.Fatalf("New: %v", err)
+            }
+        })
+    }
+}
@@ -0,0 +1,434 @@
+/*
+    This file implements the install steps pipeline for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "context"
+    "runtime"
+    "fmt"
+    "os"
+)
+
+/*
+    An install step: Plan returns actions without side
+    effects, Apply installs and Rollback reverts Apply
+    when a later step fails (it's also called for the
+    failed step). Plan and Rollback are optional.
+*/
+type Step struct {
+    Name string
+    Plan func(ctx context.Context, installer *Installer) ([]Action, error)
+    Apply func(ctx context.Context, installer *Installer) error
+    Rollback func(ctx context.Context, installer *Installer) error
+}
+
+/*
+    This function returns built-in install steps, in order:
+
+     - privileges: check privileges
+     - choices: wizard, license and install receipt
+     - directories: create software directories
+     - stop: stop running services of the previous install
+     - files: install payload files and remove files of unselected components
+     - logrotate: configure log rotation
+     - path: add programs to the PATH
+     - commands: run commands
+     - restart: start services stopped before the files replacement
+     - services: enable/start services
+     - checks: run health checks
+     - receipt: save the install receipt
+*/
+func builtin_steps() []Step {
+    return []Step{
+        {
+            Name: "privileges",
+            Apply: func(ctx context.Context, installer *Installer) error {
+                return installer.require_privileges()
+            },
+        },
+        {
+            Name: "choices",
+            Apply: func(ctx context.Context, installer *Installer) error {
+                return installer.apply_choices()
+            },
+        },
+        receipt_step("directories", (*Installer).plan_directories, func(ctx context.Context, installer *Installer) error {
+            _, err := installer.create_directories()
+            return err
+        }),
+        {
+            Name: "stop",
+            Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+                return installer.plan_stop_services(ctx)
+            },
+            Apply: func(ctx context.Context, installer *Installer) error {
+                return installer.stop_services(ctx)
+            },
+            Rollback: func(ctx context.Context, installer *Installer) error {
+                installer.restart_services(ctx)
+                return nil
+            },
+        },
+        receipt_step("files", (*Installer).plan_files, func(ctx context.Context, installer *Installer) error {
+            return installer.process_directories(ctx, installer.get_layout())
+        }),
+        receipt_step("logrotate", (*Installer).plan_log_rotation, func(ctx context.Context, installer *Installer) error {
+            installer.configure_log_rotation(ctx, installer.get_layout().Log)
+            return nil
+        }),
+        receipt_step("path", (*Installer).plan_path, func(ctx context.Context, installer *Installer) error {
+            if runtime.GOOS == "windows" && !installer.windows_system() {
+                return nil
+            }
+
+            err := installer.add_to_system_path(installer.get_layout().Bin)
+            if err != nil {
+                fmt.Fprintf(os.Stderr, "Error adding programs to the PATH: %v\n", err)
+            }
+            return nil
+        }),
+        {
+            Name: "commands",
+            Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+                return installer.plan_commands(ctx)
+            },
+            Apply: func(ctx context.Context, installer *Installer) error {
+                installer.run_commands(ctx)
+                return nil
+            },
+        },
+        {
+            Name: "restart",
+            Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+                return installer.plan_restart_services(ctx)
+            },
+            Apply: func(ctx context.Context, installer *Installer) error {
+                installer.restart_services(ctx)
+                return nil
+            },
+        },
+        {
+            Name: "services",
+            Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+                return installer.plan_services(ctx)
+            },
+            Apply: func(ctx context.Context, installer *Installer) error {
+                installer.start_services(ctx)
+                return nil
+            },
+            Rollback: func(ctx context.Context, installer *Installer) error {
+                installer.stop_started_services(ctx)
+                return nil
+            },
+        },
+        {
+            Name: "checks",
+            Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+                return installer.plan_checks(ctx)
+            },
+            Apply: func(ctx context.Context, installer *Installer) error {
+                return installer.run_checks(ctx)
+            },
+        },
+        {
+            Name: "receipt",
+            Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+                return []Action{{Kind: "receipt", Path: receipt_path(installer.get_layout().Data)}}, nil
+            },
+            Apply: func(ctx context.Context, installer *Installer) error {
+                return installer.save_receipt(installer.get_layout().Data)
+            },
+        },
+    }
+}
+
+/*
+    This function returns a step writing files saved in the
+    install receipt, Rollback removes files written by the
+    step except files of the previous install.
+*/
+func receipt_step(name string, plan func(*Installer, context.Context) ([]Action, error), apply func(context.Context, *Installer) error) Step {
+    start := 0
+    return Step{
+        Name: name,
+        Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+            return plan(installer, ctx)
+        },
+        Apply: func(ctx context.Context, installer *Installer) error {
+            start = len(installer.receipt.Files)
+            return apply(ctx, installer)
+        },
+        Rollback: func(ctx context.Context, installer *Installer) error {
+            installer.rollback_receipt(start)
+            return nil
+        },
+    }
+}
+
+/*
+    This method removes files saved in the install receipt
+    since a position, in reverse order, files of the previous
+    install are kept and restored from their backup. Data
+    files are removed: existing data files are never written.
+*/
+func (installer *Installer) rollback_receipt(start int) {
+    if start > len(installer.receipt.Files) {
+        return
+    }
+
+    for index := len(installer.receipt.Files) - 1; index >= start; index-- {
+        entry := installer.receipt.Files[index]
+        if installer.previous_contains(entry.Path) {
+            continue
+        }
+
+        if entry.Category == "data" && installer.target.Remove(entry.Path) == nil {
+            fmt.Printf("Removed: %s\n", entry.Path)
+        } else {
+            installer.remove_receipt_entry(entry)
+        }
+    }
+    installer.receipt.Files = installer.receipt.Files[:start]
+    installer.restore_backups()
+}
+
+/*
+    This method saves a file of the previous install (or an
+    adopted file) before it's replaced: the file is moved next
+    to it with the backup extension. It's safe to call from
+    multiple goroutines for different files.
+*/
+func (installer *Installer) backup_file(path string) error {
+    if !installer.previous_contains(path) && !contains(installer.adopted, path) {
+        return nil
+    }
+
+    installer.backups_lock.Lock()
+    defer installer.backups_lock.Unlock()
+
+    if contains(installer.backups, path) {
+        return nil
+    }
+
+    information, err := installer.target.Lstat(path)
+    if err != nil || !information.Mode().IsRegular() {
+        return nil
+    }
+
+    err = installer.target.Rename(path, path + backup_extension)
+    if err == nil {
+        installer.backups = append(installer.backups, path)
+    }
+    return err
+}
+
+/*
+    This method restores files of the previous install
+    replaced by a rolled back upgrade.
+*/
+func (installer *Installer) restore_backups() {
+    for index := len(installer.backups) - 1; index >= 0; index-- {
+        path := installer.backups[index]
+        installer.target.Remove(path)
+        err := installer.target.Rename(path + backup_extension, path)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error restoring %s: %v\n", path, err)
+            continue
+        }
+        fmt.Printf("Restored: %s\n", path)
+    }
+    installer.backups = nil
+}
+
+/*
+    This method removes backups of replaced files
+    when the install succeeds.
+*/
+func (installer *Installer) remove_backups() {
+    for _, path := range installer.backups {
+        installer.target.Remove(path + backup_extension)
+    }
+    installer.backups = nil
+}
+
+/*
+    This method checks if a path is in the previous install receipt.
+*/
+func (installer *Installer) previous_contains(path string) bool {
+    for _, entry := range installer.previous_receipt.Files {
+        if entry.Path == path {
+            return true
+        }
+    }
+    return false
+}
+
+/*
+    This method adds a step before a step (built-in
+    or custom), it returns an error when the step
+    name is unknown.
+*/
+func (installer *Installer) AddStepBefore(name string, step Step) error {
+    return installer.insert_step(name, 0, step)
+}
+
+/*
+    This method adds a step after a step (built-in
+    or custom), it returns an error when the step
+    name is unknown.
+*/
+func (installer *Installer) AddStepAfter(name string, step Step) error {
+    return installer.insert_step(name, 1, step)
+}
+
+/*
+    This method removes a step, for example the "path"
+    step to keep programs out of the PATH.
+*/
+func (installer *Installer) RemoveStep(name string) error {
+    for index, existing := range installer.steps {
+        if existing.Name == name {
+            installer.steps = append(installer.steps[:index], installer.steps[index + 1:]...)
+            return nil
+        }
+    }
+    return failure(ExitUsage, "unknown install step: %s", name)
+}
+
+/*
+    This method inserts a step at an offset from a named step.
+*/
+func (installer *Installer) insert_step(name string, offset int, step Step) error {
+    if step.Name == "" || step.Apply == nil {
+        return failure(ExitUsage, "a step requires a name and an Apply function")
+    }
+
+    for index, existing := range installer.steps {
+        if existing.Name == name {
+            index += offset
+            installer.steps = append(installer.steps[:index], append([]Step{step}, installer.steps[index:]...)...)
+            return nil
+        }
+    }
+    return failure(ExitUsage, "unknown install step: %s", name)
+}
+
+/*
+    This method returns names of install steps in order.
+*/
+func (installer *Installer) Steps() []string {
+    var names []string
+    for _, step := range installer.steps {
+        names = append(names, step.Name)
+    }
+    return names
+}
+
+/*
+    This method applies install steps in order, when a step
+    fails the failed step and applied steps are rolled back
+    in reverse order and the step error is returned. Backups
+    of replaced files are removed when all steps succeed.
+*/
+func (installer *Installer) apply_steps(ctx context.Context) error {
+    for index, step := range installer.steps {
+        err := ctx.Err()
+        if err == nil {
+            err = step.Apply(ctx, installer)
+        }
+
+        if err != nil {
+            if err != Cancelled {
+                installer.rollback_steps(ctx, index)
+            }
+            return err
+        }
+    }
+
+    installer.remove_backups()
+    return nil
+}
+
+/*
+    This method rolls back steps from an index to the first step.
+*/
+func (installer *Installer) rollback_steps(ctx context.Context, last int) {
+    for index := last; index >= 0; index-- {
+        step := installer.steps[index]
+        if step.Rollback == nil {
+            continue
+        }
+
+        fmt.Fprintf(os.Stderr, "Rollback: %s\n", step.Name)
+        err := step.Rollback(context.WithoutCancel(ctx), installer)
+        if err != nil {
+            fmt.Fprintf(os.Stderr, "Error rolling back %s: %v\n", step.Name, err)
+        }
+    }
+}
+
+/*
+    This function returns a custom step running a command
+    (variables are expanded), the rollback command runs
+    when a later step fails. For example:
+
+        installer.CommandStep("register alternatives", "update-alternatives --install /usr/bin/editor editor /opt/app/bin/editor 50", "update-alternatives --remove editor /opt/app/bin/editor")
+*/
+func CommandStep(name string, command string, rollback string) Step {
+    step := Step{
+        Name: name,
+        Plan: func(ctx context.Context, installer *Installer) ([]Action, error) {
+            return []Action{{Kind: "command", Source: installer.expand_variables(command)}}, nil
+        },
+        Apply: func(ctx context.Context, installer *Installer) error {
+            return installer.run_command(ctx, installer.expand_variables(command))
+        },
+    }
+
+    if rollback != "" {
+        step.Rollback = func(ctx context.Context, installer *Installer) error {
+            return installer.run_command(ctx, installer.expand_variables(rollback))
+        }
+    }
+    return step
+}
+
+/*
+    This method returns the install directories, custom
+    steps use it to write files next to the payload.
+*/
+func (installer *Installer) Layout() Layout {
+    return installer.get_layout()
+}
+
+/*
+    This method returns the value of a template variable.
+*/
+func (installer *Installer) Variable(name string) string {
+    return installer.variables[name]
+}
+
+/*
+    This method saves a file written by a custom step
+    in the install receipt, it's removed on uninstall
+    and when the install is rolled back.
+*/
+func (installer *Installer) Record(path string, category string) {
+    installer.receipt.Files = append(installer.receipt.Files, ReceiptFile{Path: path, Category: category})
+}
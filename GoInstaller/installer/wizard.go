@@ -0,0 +1,344 @@
+/*
+    This file implements the interactive wizard for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+package installer
+
+import (
+    "path/filepath"
+    "strconv"
+    "strings"
+    "io/fs"
+    "fmt"
+)
+
+/*
+    This method checks if files of a category are installed,
+    categories are selected in the wizard when the manifest
+    doesn't define components (nil when all are installed).
+*/
+func (installer *Installer) category_selected(category string) bool {
+    return installer.selected_categories == nil || installer.selected_categories[category]
+}
+
+/*
+    This method asks a question and returns the
+    answer or the default value for an empty answer.
+*/
+func (installer *Installer) ask(question string, default_value string) string {
+    if default_value != "" {
+        fmt.Printf("%s [%s]: ", question, default_value)
+    } else {
+        fmt.Printf("%s: ", question)
+    }
+
+    answer, err := installer.input.ReadString('\n')
+    if err != nil && answer == "" {
+        fmt.Println()
+        return default_value
+    }
+
+    answer = strings.TrimSpace(answer)
+    if answer == "" {
+        return default_value
+    }
+    return answer
+}
+
+/*
+    This method asks a yes/no question.
+*/
+func (installer *Installer) confirm(question string, default_value bool) bool {
+    choices := "y/N"
+    if default_value {
+        choices = "Y/n"
+    }
+
+    answer := strings.ToLower(installer.ask(question + " (" + choices + ")", ""))
+    if answer == "" {
+        return default_value
+    }
+    return answer == "y" || answer == "yes"
+}
+
+/*
+    This method runs the interactive wizard:
+
+     1. Welcome
+     2. License
+     3. Components selection
+     4. Install location
+     5. Variables
+     6. Services start policy
+     7. Summary
+
+    It returns false when the user cancels the installation,
+    files are installed with the progress view.
+*/
+func (installer *Installer) run_wizard() (bool, error) {
+    fmt.Printf("\n=== %s installer ===\n\n", installer.name)
+    fmt.Printf("This wizard installs %s on this computer.\n", installer.name)
+    installer.ask("Press Enter to continue", "")
+
+    accepted, err := installer.wizard_license()
+    if err != nil || !accepted || !installer.wizard_components() {
+        return false, err
+    }
+
+    installer.wizard_location()
+    installer.wizard_variables()
+    installer.wizard_service_start()
+
+    if !installer.wizard_summary() {
+        return false, nil
+    }
+
+    installer.options.Progress = true
+    return true, nil
+}
+
+/*
+    This method shows the license and asks the user to accept it.
+*/
+func (installer *Installer) wizard_license() (bool, error) {
+    license, err := installer.license_text()
+    if err != nil || license == "" {
+        return err == nil, err
+    }
+
+    if installer.options.AcceptLicense {
+        installer.accept_license(license, "--accept-license")
+        return true, nil
+    }
+
+    return installer.ask_license(license), nil
+}
+
+/*
+    This method asks which manifest components are
+    installed, required components can't be unselected.
+*/
+func (installer *Installer) wizard_named_components() bool {
+    for {
+        fmt.Printf("\n--- Components ---\n\n")
+        for index, component := range installer.manifest.Components {
+            mark := " "
+            if installer.selected_components[component.Name] {
+                mark = "x"
+            }
+            if component.Required {
+                mark = "*"
+            }
+            fmt.Printf(" %d. [%s] %s %s\n", index + 1, mark, component.Name, component.Description)
+        }
+
+        answer := installer.ask("\nType a number to select/unselect a component, Enter to continue", "")
+        if answer == "" {
+            return true
+        }
+
+        index, err := strconv.Atoi(answer)
+        if err != nil || index < 1 || index > len(installer.manifest.Components) {
+            fmt.Printf("Invalid choice: %s\n", answer)
+            continue
+        }
+
+        component := installer.manifest.Components[index - 1]
+        if component.Required {
+            fmt.Printf("Component %s is required.\n", component.Name)
+            continue
+        }
+
+        installer.selected_components[component.Name] = !installer.selected_components[component.Name]
+        if !installer.selected_components[component.Name] {
+            for _, other := range installer.manifest.Components {
+                if installer.selected_components[other.Name] && contains(other.Depends, component.Name) {
+                    fmt.Printf("Component %s is required by %s.\n", component.Name, other.Name)
+                    installer.selected_components[component.Name] = true
+                    break
+                }
+            }
+        }
+
+        err = installer.resolve_dependencies(nil)
+        if err != nil {
+            fmt.Println(err)
+            return false
+        }
+    }
+}
+
+/*
+    This method asks which payload categories are installed,
+    components are asked instead when the manifest defines them.
+*/
+func (installer *Installer) wizard_components() bool {
+    if len(installer.manifest.Components) > 0 {
+        return installer.wizard_named_components()
+    }
+
+    var available []string
+    for _, category := range categories {
+        if installer.category_has_files(category) {
+            available = append(available, category)
+        }
+    }
+
+    selected := make(map[string]bool)
+    for _, category := range available {
+        selected[category] = true
+    }
+
+    for {
+        fmt.Printf("\n--- Components ---\n\n")
+        for index, category := range available {
+            mark := " "
+            if selected[category] {
+                mark = "x"
+            }
+            fmt.Printf(" %d. [%s] %s\n", index + 1, mark, category)
+        }
+
+        answer := installer.ask("\nType a number to select/unselect a component, Enter to continue", "")
+        if answer == "" {
+            break
+        }
+
+        index, err := strconv.Atoi(answer)
+        if err != nil || index < 1 || index > len(available) {
+            fmt.Printf("Invalid choice: %s\n", answer)
+            continue
+        }
+        selected[available[index - 1]] = !selected[available[index - 1]]
+    }
+
+    installer.selected_categories = selected
+    for _, category := range available {
+        if selected[category] {
+            return true
+        }
+    }
+
+    fmt.Println("No component selected.")
+    return false
+}
+
+/*
+    This method checks if a category contains
+    embedded or remote files.
+*/
+func (installer *Installer) category_has_files(category string) bool {
+    entries, err := fs.ReadDir(installer.payload, category)
+    if err == nil && len(entries) > 0 {
+        return true
+    }
+
+    for _, remote := range installer.manifest.Remote {
+        if remote.Category == category {
+            return true
+        }
+    }
+    return false
+}
+
+/*
+    This method asks the programs install location.
+*/
+func (installer *Installer) wizard_location() {
+    fmt.Printf("\n--- Install location ---\n\n")
+    default_location := installer.get_layout().Bin
+    location := filepath.Clean(installer.ask("Programs directory", default_location))
+    if location != default_location {
+        installer.options.Location = location
+    }
+}
+
+/*
+    This method asks values of manifest variables.
+*/
+func (installer *Installer) wizard_variables() {
+    if len(installer.manifest.Variables) == 0 {
+        return
+    }
+
+    fmt.Printf("\n--- Settings ---\n\n")
+    for _, variable := range installer.manifest.Variables {
+        question := variable.Name
+        if variable.Description != "" {
+            question = variable.Description + " (" + variable.Name + ")"
+        }
+        installer.variables[variable.Name] = installer.ask(question, installer.variables[variable.Name])
+    }
+}
+
+/*
+    This method asks the services start policy
+    when the payload contains services.
+*/
+func (installer *Installer) wizard_service_start() {
+    if !installer.category_has_files("service") || !installer.category_selected("service") {
+        return
+    }
+
+    fmt.Printf("\n--- Services ---\n\n")
+    for {
+        default_policy := installer.options.ServiceStart
+        if default_policy == "" {
+            default_policy = default_service_start()
+        }
+
+        policy := installer.ask("Services start policy (start, enable, none)", default_policy)
+        if contains(service_start_policies, policy) {
+            installer.options.ServiceStart = policy
+            return
+        }
+        fmt.Printf("Invalid choice: %s\n", policy)
+    }
+}
+
+/*
+    This method prints install choices and asks
+    the user to start the installation.
+*/
+func (installer *Installer) wizard_summary() bool {
+    layout := installer.get_layout()
+    fmt.Printf("\n--- Summary ---\n\n")
+    fmt.Printf(" Application: %s\n", installer.name)
+    fmt.Printf(" Programs:    %s\n", layout.Bin)
+    fmt.Printf(" Data:        %s\n", layout.Data)
+    fmt.Printf(" Config:      %s\n", layout.Config)
+    if installer.options.ServiceStart != "" {
+        fmt.Printf(" Services:    %s\n", installer.options.ServiceStart)
+    }
+
+    components := installer.installed_components()
+    if len(installer.manifest.Components) == 0 {
+        for _, category := range categories {
+            if installer.selected_categories[category] {
+                components = append(components, category)
+            }
+        }
+    }
+    fmt.Printf(" Components:  %s\n\n", strings.Join(components, ", "))
+
+    if !installer.confirm("Start the installation?", true) {
+        return false
+    }
+
+    fmt.Printf("\n--- Installation ---\n\n")
+    return true
+}
@@ -1,5 +1,5 @@
 /*
-    This file implements an installer for Linux and Windows softwares
+    This file implements the command line installer for GoInstaller
     Copyright (C) 2025  Maurice Lambert
 
     This program is free software: you can redistribute it and/or modify
@@ -16,252 +16,192 @@
     along with this program.  If not, see <https://www.gnu.org/licenses/>.
 */
 
-// go build -o installer.exe GoInstaller.go
+// go build -o installer.exe
 
 package main
 
 import (
-    "path/filepath"
-    "runtime"
-    "os/exec"
+    "GoInstaller/installer"
+    "os/signal"
+    "context"
+    "strings"
     "errors"
-    "io/fs"
-    "embed"
+    "flag"
     "fmt"
     "os"
+    _ "embed"
 )
 
-//go:embed data/*
-var data_files embed.FS
-//go:embed program/*
-var program_files embed.FS
-//go:embed gui/*
-var program_gui_files embed.FS
-//go:embed service/*
-var service_files embed.FS
-const application_name = "${APPLICATION_NAME}"
-
-type File struct {
-    filetype string
-    path string
-    name string
-    data []byte
-    callback func(string)
-}
-
-type RegistryKey struct {
-    value_name string
-    value_data any
-}
+//go:embed manifest.json
+var manifest_data []byte
+var application_name = "${APPLICATION_NAME}"
+const copy_buffer_size = 256 * 1024
+var extract_to string
 
 /*
     The main function to starts the installer.
 
-    1. Check privileges
-    2. Create directories
-    3. Install/Write files
-    4. Run commands
+    The installer logic is in the "installer" package, this
+    program embeds the payload, parses the command line and
+    returns the exit code of installer errors.
+
+    Run with the "uninstall" argument to remove installed files
+    with "plan" to print install actions without changes, with
+    "export" to build a package from the payload and with
+    "extract" to write files in a portable directory.
+    Without privileges, install and uninstall are run again
+    with sudo, doas or pkexec on Linux.
 */
 func main() {
-    priviliges, err := check_privileges()
-    if err != nil || !priviliges {
-        fmt.Fprintf(os.Stderr, "This software installer require privileges.\n")
-        if err != nil {
-            fmt.Fprintf(os.Stderr, "Error checking privileges: %v\n", err)
-        }
-        os.Exit(5)
+    command, arguments, options := parse_arguments()
+    options.Name = application_name
+    options.Payload = load_payload()
+    options.Manifest = manifest_data
+    options.Signature = signature_files
+    options.PublicKey = payload_public_key
+    options.WindowsCommands = []string{${WINDOWS_COMMANDS}} // Insert your Windows commands here
+    options.LinuxCommands = []string{${LINUX_COMMANDS}} // Insert your Linux commands here
+
+    ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+    defer stop()
+
+    setup, err := installer.New(options)
+    if err == nil {
+        err = run_command(ctx, setup, command, arguments)
     }
 
-    program_directory, data_directory := create_directories()
-    process_directories(program_directory, data_directory)
-
-    if runtime.GOOS == "windows" {
-        add_to_system_path(program_directory)
+    if exit_code(err) == installer.ExitPrivileges && (command == "install" || command == "uninstall") {
+        err = setup.Elevate(os.Args[1:])
     }
 
-    run_commands()
-
-    fmt.Println("Installation completed successfully!")
-    os.Exit(0)
-}
-
-/*
-    This function creates software directories.
-*/
-func create_directories() (string, string) {
-    var program_files_dir, program_data_dir string
-    if runtime.GOOS == "windows" {
-        program_files_dir = os.Getenv("PROGRAMFILES")
-        program_data_dir = os.Getenv("PROGRAMDATA")
-    } else {
-        program_files_dir = "/usr/local/bin"
-        program_data_dir = "/var/lib"
-    }
-
-    program_files_dir = filepath.Join(program_files_dir, application_name)
-    program_data_dir = filepath.Join(program_data_dir, application_name)
-    create_directory(program_files_dir)
-    create_directory(program_data_dir)
-
-    if runtime.GOOS == "windows" {
-        add_application_source_log(application_name)
-        // new_registry_key(`SYSTEM\CurrentControlSet\Services\EventLog\Application\` + application_name, []RegistryKey{{"CustomSource", 1}, {"EventMessageFile", `%SystemRoot%\System32\EventCreate.exe`}, {"TypesSupported", 7}})
-    } else {
-        create_directory("/var/log/" + application_name)
+    if errors.Is(err, installer.Cancelled) {
+        fmt.Println("Installation cancelled.")
+        return
     }
 
-    return program_files_dir, program_data_dir
-}
-
-/*
-    This function creates a directory and exit on error.
-*/
-func create_directory(path string) {
-    err := os.MkdirAll(path, os.ModePerm)
     if err != nil {
-        fmt.Fprintf(os.Stderr, "Error creating directory %s: %v\n", path, err)
-        os.Exit(1)
+        fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+        stop()
+        os.Exit(exit_code(err))
     }
 }
 
 /*
-    This function calls process_directory function multiples
-    times and defines the system directory to use.
+    This function runs a command line command and
+    prints the success message.
 */
-func process_directories(program_directory, data_directory string) {
-    file := File{}
-    file.path = data_directory
-    file.filetype = "data"
-    process_directory(data_files, file)
-
-    file.path = program_directory
-    file.filetype = "program"
-    process_directory(program_files, file)
-
-    file.path = program_directory
-    file.filetype = "gui"
-    if runtime.GOOS == "windows" {
-        file.callback = add_to_windows_menu
+func run_command(ctx context.Context, setup *installer.Installer, command string, arguments []string) error {
+    switch command {
+    case "uninstall":
+        err := setup.Uninstall(ctx)
+        if err == nil {
+            fmt.Println("Uninstallation completed successfully!")
+        }
+        return err
+    case "plan":
+        plan, err := setup.Plan(ctx)
+        if err == nil {
+            for _, action := range plan.Actions {
+                fmt.Printf("%-11s %-9s %s %s\n", action.Step, action.Kind, action.Path, action.Source)
+            }
+        }
+        return err
+    case "export":
+        if len(arguments) != 2 {
+            fmt.Fprintf(os.Stderr, "USAGE: installer export deb|rpm|tar|layer|oci output\n")
+            os.Exit(installer.ExitUsage)
+        }
+        err := setup.Export(ctx, arguments[0], arguments[1])
+        if err == nil {
+            fmt.Printf("Package written: %s\n", arguments[1])
+        }
+        return err
+    case "extract":
+        if extract_to == "" {
+            fmt.Fprintf(os.Stderr, "USAGE: installer extract --to directory\n")
+            os.Exit(installer.ExitUsage)
+        }
+        err := setup.Extract(ctx, extract_to)
+        if err == nil {
+            fmt.Println("Extraction completed successfully!")
+        }
+        return err
     }
-    process_directory(program_gui_files, file)
 
-    if runtime.GOOS == "windows" {
-        file.path = program_directory
-        file.callback = create_service
-    } else {
-        file.path = "/etc/systemd/system/"
+    err := setup.Install(ctx)
+    if err == nil {
+        fmt.Println("Installation completed successfully!")
     }
-
-    file.filetype = "service"
-    process_directory(service_files, file)
+    return err
 }
 
 /*
-    This function reads directory from embeded files.
+    This function returns the exit code of an installer error.
 */
-func process_directory(files embed.FS, file File) {
-    file_entries, err := files.ReadDir(file.filetype)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error reading embedded files (%s): %v\n", file.filetype, err)
-        return
-    }
-
-    for _, entry := range file_entries {
-        process_file(files, entry, file)
+func exit_code(err error) int {
+    var failure *installer.Error
+    if errors.As(err, &failure) {
+        return failure.Code
     }
+    return 1
 }
 
-
 /*
-    This function reads file from embeded files.
+    This function parses command line arguments, the first
+    argument is the command (install, uninstall, plan, export
+    or extract), "install" is the default.
 */
-func process_file(files embed.FS, entry fs.DirEntry, file File) {
-    file.name = entry.Name()
-    file_path := file.filetype + "/" + file.name
-
-    file_data, err := files.ReadFile(file_path)
-    if err != nil {
-        fmt.Fprintf(os.Stderr, "Error reading file %s: %v\n", file.name, err)
-        return
+func parse_arguments() (string, []string, installer.Options) {
+    var options installer.Options
+    var with, without string
+
+    arguments := os.Args[1:]
+    command := "install"
+    if len(arguments) > 0 && !strings.HasPrefix(arguments[0], "-") {
+        command = arguments[0]
+        arguments = arguments[1:]
     }
-    file.data = file_data
-
-    fullfilepath := write_file(file)
 
-    if file.callback != nil {
-        file.callback(fullfilepath)
+    flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+    flags.BoolVar(&options.Yes, "yes", false, "install without the interactive wizard")
+    flags.BoolVar(&options.AcceptLicense, "accept-license", false, "accept the license without prompt")
+    flags.BoolVar(&options.Adopt, "adopt", false, "replace existing files in shared directories (install without receipt)")
+    flags.StringVar(&with, "with", "", "comma separated components to install")
+    flags.StringVar(&without, "without", "", "comma separated components to skip")
+    flags.StringVar(&options.Answers, "answers", "", "JSON or YAML answer file with install choices (no prompt)")
+    flags.StringVar(&options.RecordAnswers, "record-answers", "", "write install choices in a JSON (or .yaml) answer file")
+    flags.StringVar(&options.ServiceStart, "service-start", "", "services policy: start, enable or none")
+    flags.StringVar(&extract_to, "to", "", "directory of the extract command")
+    flags.DurationVar(&options.LockTimeout, "lock-timeout", 0, "time to wait for another installation (default 1m, negative: no wait)")
+    flags.DurationVar(&options.ServiceStopTimeout, "service-stop-timeout", 0, "time to wait for running services to stop (default 30s)")
+    flags.Parse(arguments)
+
+    options.With = split_list(with)
+    options.Without = split_list(without)
+
+    arguments = flags.Args()
+    if command == "install" && len(arguments) > 0 {
+        command = arguments[0]
+        arguments = arguments[1:]
     }
-}
-
-/*
-    This function checks if file exists.
-*/
-func file_exists(file_path string) bool {
-    _, err := os.Stat(file_path)
-    return !errors.Is(err, os.ErrNotExist)
-}
-
-/*
-    This function writes the file content or exit on error.
-*/
-func write_file(file File) string {
-    fullfilepath := filepath.Join(file.path, file.name)
-    if file.filetype != "data" || !file_exists(fullfilepath) {
-        err := os.WriteFile(fullfilepath, file.data, 0755)
-
-        if err != nil {
-            fmt.Fprintf(os.Stderr, "Error writing file %s: %v\n", fullfilepath, err)
-            os.Exit(2)
-        }
 
-        fmt.Printf("Installed: %s\n", fullfilepath)
-    } else {
-        fmt.Printf("Data file already exists: %s\n", fullfilepath)
+    if command != "install" && command != "uninstall" && command != "plan" && command != "export" && command != "extract" {
+        fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
+        os.Exit(installer.ExitUsage)
     }
-    return fullfilepath
+    return command, arguments, options
 }
 
 /*
-    This function executes system commands when is
-    required for the software install.
+    This function splits a comma separated list.
 */
-func run_commands() {
-    var commands []string
-
-    if runtime.GOOS == "windows" {
-        commands = []string{${WINDOWS_COMMANDS}} // Insert your Windows commands here
-    } else {
-        commands = []string{${LINUX_COMMANDS}} // Insert your Linux commands here
-    }
-
-    for _, command := range commands {
-        var cmd *exec.Cmd
-        if runtime.GOOS == "windows" {
-            cmd = execute_windows_command(command)
-        } else {
-            cmd = exec.Command("sh", "-c", command)
+func split_list(list string) []string {
+    var values []string
+    for _, value := range strings.Split(list, ",") {
+        value = strings.TrimSpace(value)
+        if value != "" {
+            values = append(values, value)
         }
-
-        // err := cmd.Run()
-        out, err := cmd.CombinedOutput()
-
-        if err != nil {
-            fmt.Fprintf(os.Stderr, "Command error: %v\n", err)
-        }
-
-        fmt.Printf("Ouput: %s\n", string(out))
-    }
-}
-
-/*
-    This function checks if process have privileges
-    to install the software.
-*/
-func check_privileges() (bool, error) {
-    switch runtime.GOOS {
-    case "windows":
-        return check_administrator()
-    default:
-        return check_root()
     }
+    return values
 }
@@ -0,0 +1,50 @@
+/*
+    This file implements the compressed payload for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// go run ./cmd/goinstaller compress
+// go build -tags compressed -o installer.exe
+
+//go:build compressed && !stub
+
+package main
+
+import (
+    "archive/zip"
+    "io/fs"
+    "bytes"
+    "fmt"
+    "os"
+    _ "embed"
+)
+
+//go:embed payload.zip
+var compressed_payload []byte
+
+/*
+    This function returns the payload files from the
+    embedded archive, files are decompressed when they
+    are read and checked with the archive CRC32.
+*/
+func load_payload() fs.FS {
+    archive, err := zip.NewReader(bytes.NewReader(compressed_payload), int64(len(compressed_payload)))
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error opening the compressed payload: %v\n", err)
+        os.Exit(3)
+    }
+    return archive
+}
@@ -0,0 +1,36 @@
+/*
+    This file implements the uncompressed payload for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+//go:build !compressed && !stub
+
+package main
+
+import (
+    "io/fs"
+    "embed"
+)
+
+//go:embed data/* program/* gui/* service/* config/*
+var embedded_payload embed.FS
+
+/*
+    This function returns the payload files embedded raw.
+*/
+func load_payload() fs.FS {
+    return embedded_payload
+}
@@ -0,0 +1,114 @@
+/*
+    This file implements the self-extracting payload for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// go build -tags stub -o stub
+// goinstaller pack stub /path/to/payload/directory installer
+
+//go:build stub
+
+package main
+
+import (
+    "encoding/binary"
+    "crypto/sha256"
+    "archive/zip"
+    "io/fs"
+    "bytes"
+    "fmt"
+    "io"
+    "os"
+)
+
+/*
+    The trailer at the end of the executable:
+
+     - magic (8 bytes)
+     - archive size (8 bytes, little endian), the
+       archive is written just before the trailer
+     - archive SHA256 (32 bytes)
+*/
+const trailer_magic = "GOINSTPL"
+const trailer_size = 8 + 8 + sha256.Size
+
+/*
+    This function returns the payload files from the
+    archive appended to the installer executable.
+*/
+func load_payload() fs.FS {
+    path, err := os.Executable()
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error locating the installer executable: %v\n", err)
+        os.Exit(3)
+    }
+
+    file, err := os.Open(path)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error opening the installer executable: %v\n", err)
+        os.Exit(3)
+    }
+
+    archive, err := read_appended_payload(file)
+    if err != nil {
+        fmt.Fprintf(os.Stderr, "Error reading the appended payload: %v\n", err)
+        os.Exit(3)
+    }
+    return archive
+}
+
+/*
+    This function checks the trailer and the archive
+    checksum and opens the appended archive.
+*/
+func read_appended_payload(file *os.File) (*zip.Reader, error) {
+    information, err := file.Stat()
+    if err != nil {
+        return nil, err
+    }
+
+    if information.Size() < trailer_size {
+        return nil, fmt.Errorf("no payload appended")
+    }
+
+    trailer := make([]byte, trailer_size)
+    _, err = file.ReadAt(trailer, information.Size() - trailer_size)
+    if err != nil {
+        return nil, err
+    }
+
+    if string(trailer[:8]) != trailer_magic {
+        return nil, fmt.Errorf("no payload appended")
+    }
+
+    size := int64(binary.LittleEndian.Uint64(trailer[8:16]))
+    offset := information.Size() - trailer_size - size
+    if size <= 0 || offset < 0 {
+        return nil, fmt.Errorf("invalid payload size")
+    }
+
+    hasher := sha256.New()
+    _, err = io.CopyBuffer(hasher, io.NewSectionReader(file, offset, size), make([]byte, copy_buffer_size))
+    if err != nil {
+        return nil, err
+    }
+
+    if !bytes.Equal(hasher.Sum(nil), trailer[16:]) {
+        return nil, fmt.Errorf("invalid payload checksum")
+    }
+
+    return zip.NewReader(io.NewSectionReader(file, offset, size), size)
+}
@@ -0,0 +1,34 @@
+/*
+    This file embeds the payload signature for GoInstaller
+    Copyright (C) 2025  Maurice Lambert
+
+    This program is free software: you can redistribute it and/or modify
+    it under the terms of the GNU General Public License as published by
+    the Free Software Foundation, either version 3 of the License, or
+    (at your option) any later version.
+
+    This program is distributed in the hope that it will be useful,
+    but WITHOUT ANY WARRANTY; without even the implied warranty of
+    MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+    GNU General Public License for more details.
+
+    You should have received a copy of the GNU General Public License
+    along with this program.  If not, see <https://www.gnu.org/licenses/>.
+*/
+
+// go run ./cmd/goinstaller sign private.key
+// go build -ldflags "-X main.payload_public_key=<hexadecimal public key>" -o installer.exe
+
+package main
+
+import "embed"
+
+//go:embed signature/*
+var signature_files embed.FS
+
+/*
+    The ed25519 public key pinned at build time, when it's
+    empty the payload signature is not checked.
+*/
+var payload_public_key = ""
+